@@ -0,0 +1,159 @@
+package friendlycaptcha
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed is the normal state: all requests are sent to the Friendly Captcha API.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means that the Friendly Captcha API is considered unavailable: requests fail immediately with
+	// ErrCircuitOpen without being sent.
+	CircuitOpen
+	// CircuitHalfOpen means that the cooldown has passed and a single probe request is let through to check whether
+	// the Friendly Captcha API has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops requests to the Friendly Captcha API after a number of consecutive failures, so that callers
+// don't have to wait for a timeout on every request while the API is unreachable.
+//
+// After the cooldown a single probe request is let through: if it succeeds the circuit closes again, if it fails the
+// circuit stays open for another cooldown.
+//
+// A CircuitBreaker is safe for concurrent use, and can be shared between multiple clients that talk to the same
+// API endpoint.
+type CircuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type breakerOutcome int
+
+const (
+	breakerSuccess breakerOutcome = iota
+	breakerFailure
+	// breakerIgnored is for outcomes that say nothing about the health of the API, e.g. a request that could not
+	// be created.
+	breakerIgnored
+)
+
+// NewCircuitBreaker returns a CircuitBreaker that opens after failureThreshold consecutive failed requests, and
+// probes for recovery after the given cooldown.
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+// WithCircuitBreaker sets the circuit breaker that guards requests to the Friendly Captcha API.
+//
+// While the circuit is open `VerifyCaptchaResponse` returns immediately with a result for which `IsCircuitOpen()` is
+// true. Just like when the API could not be reached, `ShouldAccept()` will return true for it unless strict mode is
+// enabled.
+//
+// By default no circuit breaker is used.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(c *Client) error {
+		c.CircuitBreaker = breaker
+		return nil
+	}
+}
+
+// State returns the current state of the circuit, which is useful for monitoring and alerting.
+func (b *CircuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.cooldownPassed() {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) cooldownPassed() bool {
+	return b.now().Sub(b.openedAt) >= b.cooldown
+}
+
+// allow returns whether a request may be sent. Every allowed request must be followed by a call to record.
+func (b *CircuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if !b.cooldownPassed() {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	default: // CircuitHalfOpen
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+}
+
+func (b *CircuitBreaker) record(outcome breakerOutcome) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch outcome {
+	case breakerSuccess:
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+	case breakerFailure:
+		b.failures++
+		if b.state == CircuitHalfOpen || b.failures >= b.failureThreshold {
+			b.state = CircuitOpen
+			b.openedAt = b.now()
+		}
+		b.probing = false
+	case breakerIgnored:
+		b.probing = false
+	}
+}
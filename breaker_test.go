@@ -0,0 +1,126 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestCircuitBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	breaker := NewCircuitBreaker(threshold, cooldown)
+	breaker.now = clock.Now
+	return breaker, clock
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	t.Parallel()
+
+	breaker, clock := newTestCircuitBreaker(2, time.Minute)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	assert.True(t, breaker.allow())
+	breaker.record(breakerFailure)
+	assert.Equal(t, CircuitClosed, breaker.State(), "one failure is below the threshold")
+
+	assert.True(t, breaker.allow())
+	breaker.record(breakerSuccess)
+	assert.True(t, breaker.allow())
+	breaker.record(breakerFailure)
+	assert.Equal(t, CircuitClosed, breaker.State(), "a success resets the consecutive failures")
+
+	assert.True(t, breaker.allow())
+	breaker.record(breakerFailure)
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.False(t, breaker.allow())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.True(t, breaker.allow(), "probe is let through")
+	assert.False(t, breaker.allow(), "only a single probe at a time")
+
+	breaker.record(breakerFailure)
+	assert.Equal(t, CircuitOpen, breaker.State(), "failed probe opens the circuit again")
+	assert.False(t, breaker.allow())
+
+	clock.Advance(time.Minute)
+	assert.True(t, breaker.allow())
+	breaker.record(breakerIgnored)
+	assert.Equal(t, CircuitHalfOpen, breaker.State(), "ignored outcome releases the probe")
+	assert.True(t, breaker.allow())
+	breaker.record(breakerSuccess)
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.True(t, breaker.allow())
+}
+
+func TestCircuitBreakerNil(t *testing.T) {
+	t.Parallel()
+
+	var breaker *CircuitBreaker
+	assert.True(t, breaker.allow())
+	breaker.record(breakerFailure)
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestClientWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, failFirst(2, respondWithStatus(http.StatusBadGateway))...)
+	breaker, clock := newTestCircuitBreaker(2, time.Minute)
+
+	for _, strict := range []bool{false, true} {
+		client, err := NewClient(
+			WithAPIKey("test-key"),
+			WithAPIEndpoint(server.URL),
+			WithCircuitBreaker(breaker),
+			WithStrictMode(strict),
+		)
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+
+		if !strict {
+			for i := 0; i < 2; i++ {
+				result := client.VerifyCaptchaResponse(context.Background(), "response")
+				assert.False(t, result.IsCircuitOpen())
+				assert.Equal(t, http.StatusBadGateway, result.Status)
+			}
+			assert.Equal(t, CircuitOpen, breaker.State())
+		}
+
+		result := client.VerifyCaptchaResponse(context.Background(), "response")
+		assert.True(t, result.IsCircuitOpen())
+		assert.True(t, result.IsRequestError())
+		assert.ErrorIs(t, result.RequestError(), ErrCircuitOpen)
+		assert.Equal(t, !strict, result.ShouldAccept())
+
+		riResult := client.RetrieveRiskIntelligence(context.Background(), "token")
+		assert.True(t, riResult.IsCircuitOpen())
+		assert.True(t, riResult.IsRequestError())
+	}
+	assert.Equal(t, int32(2), requests.Load(), "no requests are sent while the circuit is open")
+
+	clock.Advance(time.Minute)
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL), WithCircuitBreaker(breaker))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.WasAbleToVerify())
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
@@ -0,0 +1,125 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// A CallOption can be passed to `VerifyCaptchaResponse` and `RetrieveRiskIntelligence` to override the configuration
+// of the Client for a single call, e.g. to serve several sitekeys with one Client, or to use strict mode only on some
+// endpoints.
+type CallOption func(*callConfig)
+
+// callConfig is the configuration of a single call, it starts out as the configuration of the Client.
+type callConfig struct {
+	sitekey        string
+	strict         bool
+	failurePolicy  *FailurePolicy
+	timeout        time.Duration
+	header         http.Header
+	allowedOrigins []string
+	// maxChallengeAge is the maximum age of the challenge, 0 disables the check.
+	maxChallengeAge time.Duration
+	// originRequest is the request the challenge must have been solved on the origin of, see WithRequestOriginCheck.
+	originRequest *http.Request
+}
+
+// newCallConfig returns the configuration of a call with the given options.
+func (frc *Client) newCallConfig(opts []CallOption) *callConfig {
+	cfg := &callConfig{
+		sitekey:         frc.Sitekey,
+		strict:          frc.Strict,
+		failurePolicy:   frc.failurePolicy(),
+		allowedOrigins:  frc.AllowedOrigins,
+		maxChallengeAge: frc.MaxChallengeAge,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithCallSitekey sets the sitekey for the call, overriding the sitekey of the Client (see WithSitekey).
+func WithCallSitekey(sitekey string) CallOption {
+	return func(cfg *callConfig) {
+		cfg.sitekey = sitekey
+	}
+}
+
+// WithCallStrictMode sets strict mode for the call, overriding both the strict mode and the FailurePolicy of the
+// Client: all failures are rejected in strict mode, and accepted otherwise. It has no effect on
+// `RetrieveRiskIntelligence`.
+func WithCallStrictMode(strict bool) CallOption {
+	return func(cfg *callConfig) {
+		policy := strictFailurePolicy(strict)
+		cfg.strict = strict
+		cfg.failurePolicy = &policy
+	}
+}
+
+// WithCallTimeout limits how long the call may take, including retries and waiting for a slot of the
+// ConcurrencyLimiter. Unlike a deadline on the context passed to the call, exceeding the timeout is a failure to reach
+// the API (FailureTimeout), so the FailurePolicy decides whether the captcha response is accepted.
+//
+// A timeout of 0 (= the default) does not limit the call.
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(cfg *callConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithCallHeader adds a header to the requests to the Friendly Captcha API that are sent for the call. It can be
+// passed multiple times. Headers the client sets itself, like `X-Api-Key`, can not be overridden.
+func WithCallHeader(key, value string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.header == nil {
+			cfg.header = make(http.Header)
+		}
+		cfg.header.Add(key, value)
+	}
+}
+
+// WithCallExpectedOrigin only accepts the captcha response if its challenge was solved on one of the given origins,
+// overriding the allowed origins of the Client (see WithAllowedOrigins, which describes the format). Invalid origins
+// match nothing, passing no origins allows all origins. It has no effect on `RetrieveRiskIntelligence`.
+func WithCallExpectedOrigin(origins ...string) CallOption {
+	return func(cfg *callConfig) {
+		cfg.allowedOrigins = origins
+	}
+}
+
+// WithCallMaxChallengeAge only accepts the captcha response if its challenge was solved at most maxAge ago, overriding
+// the maximum challenge age of the Client (see WithMaxChallengeAge). A maxAge of 0 disables the check for the call. It
+// has no effect on `RetrieveRiskIntelligence`.
+func WithCallMaxChallengeAge(maxAge time.Duration) CallOption {
+	return func(cfg *callConfig) {
+		cfg.maxChallengeAge = max(maxAge, 0)
+	}
+}
+
+// withCallRequestOrigin only accepts the captcha response if its challenge was solved on the origin of the request, in
+// addition to the allowed origins. It is used by the middleware, see WithRequestOriginCheck.
+func withCallRequestOrigin(r *http.Request) CallOption {
+	return func(cfg *callConfig) {
+		cfg.originRequest = r
+	}
+}
+
+// withTimeout returns the context for the requests of the call.
+func (cfg *callConfig) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, cfg.timeout, errCallTimeout)
+}
+
+// errCallTimeout is the cause of the context of a call that exceeded its timeout, see WithCallTimeout. It is a
+// net.Error, so that it is classified like other timeouts.
+var errCallTimeout error = callTimeoutError{}
+
+type callTimeoutError struct{}
+
+func (callTimeoutError) Error() string   { return "call timeout exceeded" }
+func (callTimeoutError) Timeout() bool   { return true }
+func (callTimeoutError) Temporary() bool { return true }
@@ -0,0 +1,133 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallOptionsSitekeyAndHeaders(t *testing.T) {
+	t.Parallel()
+
+	handler, requests := recordRequests()
+	server, _ := newTestServer(t, handler)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithSitekey("client-sitekey"))
+
+	ctx := context.Background()
+	client.VerifyCaptchaResponse(ctx, "response")
+	client.VerifyCaptchaResponse(ctx, "response",
+		WithCallSitekey("call-sitekey"),
+		WithCallHeader("X-Request-Id", "abc"),
+		WithCallHeader("X-Api-Key", "other-key"),
+	)
+	client.RetrieveRiskIntelligence(ctx, "token", WithCallSitekey("call-sitekey"), WithCallHeader("X-Request-Id", "def"))
+
+	got := requests()
+	require.Len(t, got, 3)
+	assert.Equal(t, "client-sitekey", got[0].sitekey)
+	assert.Empty(t, got[0].header.Get("X-Request-Id"))
+	assert.Equal(t, "call-sitekey", got[1].sitekey)
+	assert.Equal(t, "abc", got[1].header.Get("X-Request-Id"))
+	assert.Equal(t, []string{"test-key"}, got[1].header.Values("X-Api-Key"), "client headers can't be overridden")
+	assert.Equal(t, "call-sitekey", got[2].sitekey)
+	assert.Equal(t, "def", got[2].header.Get("X-Request-Id"))
+	// Options don't change the client.
+	assert.Equal(t, "client-sitekey", client.Sitekey)
+}
+
+func TestCallOptionsStrictMode(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	ctx := context.Background()
+
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL))
+	require.NoError(t, err)
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "response").ShouldAccept())
+	result := client.VerifyCaptchaResponse(ctx, "response", WithCallStrictMode(true))
+	assert.True(t, result.Strict())
+	assert.False(t, result.ShouldAccept())
+
+	// The strict mode of a call also overrides the FailurePolicy of the client.
+	policy := FailClosedPolicy()
+	client, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL), WithFailurePolicy(policy))
+	require.NoError(t, err)
+	assert.False(t, client.VerifyCaptchaResponse(ctx, "response").ShouldAccept())
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "response", WithCallStrictMode(false)).ShouldAccept())
+}
+
+func TestCallOptionsTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The server never responds.
+	server, _ := newTestServer(t, respondWhenReleased(make(chan struct{}), respondSuccess))
+	client := newTestClient(t, WithAPIEndpoint(server.URL))
+
+	// Exceeding the timeout of the call is a failure to reach the API.
+	result := client.VerifyCaptchaResponse(context.Background(), "response", WithCallTimeout(20*time.Millisecond))
+	assert.Equal(t, FailureTimeout, result.FailureClass())
+	assert.False(t, result.IsContextCanceled())
+	assert.True(t, result.ShouldAccept())
+
+	retrieveResult := client.RetrieveRiskIntelligence(context.Background(), "token", WithCallTimeout(20*time.Millisecond))
+	assert.Equal(t, FailureTimeout, retrieveResult.FailureClass())
+	assert.True(t, retrieveResult.IsRequestError())
+
+	// The caller giving up is still a canceled context.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result = client.VerifyCaptchaResponse(ctx, "response", WithCallTimeout(time.Minute))
+	assert.True(t, result.IsContextCanceled())
+	assert.False(t, result.ShouldAccept())
+}
+
+func TestCallOptionsExpectedOrigin(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithAllowedOrigins("https://example.org"),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "valid").IsOriginNotAllowed())
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "valid", WithCallExpectedOrigin("https://example.com")).ShouldAccept())
+	result := client.VerifyCaptchaResponse(ctx, "valid", WithCallExpectedOrigin("https://www.example.com"))
+	assert.True(t, result.IsOriginNotAllowed())
+	result = client.VerifyCaptchaResponse(ctx, "valid", WithCallExpectedOrigin("not a valid origin"))
+	assert.True(t, result.IsOriginNotAllowed())
+}
+
+func TestMiddlewareWithCallOptions(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL))
+	require.NoError(t, err)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, tt := range []struct {
+		opts     []MiddlewareOption
+		expected int
+	}{
+		{opts: nil, expected: http.StatusOK},
+		{opts: []MiddlewareOption{WithCallOptions(WithCallStrictMode(true))}, expected: http.StatusForbidden},
+	} {
+		form := url.Values{ResponseFormFieldName: {"response"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		client.Middleware(tt.opts...)(next).ServeHTTP(rec, req)
+		assert.Equal(t, tt.expected, rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package friendlycaptcha
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultClockSkew is how much the clock of the Friendly Captcha API and the local clock may differ by default when
+// checking the age of a challenge, see WithMaxChallengeAge.
+const DefaultClockSkew = 5 * time.Second
+
+// WithMaxChallengeAge only accepts captcha responses of challenges that were solved at most maxAge ago, based on
+// `VerifyResponseChallengeData.Timestamp`. This is stricter than the API's own `response_timeout`, which is useful for
+// high-value actions like a password reset. Use WithCallMaxChallengeAge to set a different maximum age per call.
+//
+// If the challenge of a successfully verified captcha response is older, or has no timestamp, the result is rejected:
+// `ShouldAccept()` returns false and `IsChallengeTooOld()` returns true. Responses that could not be verified are not
+// affected. To tolerate clocks that are out of sync, challenges may be up to ClockSkew older (see WithClockSkew).
+//
+// This defaults to 0, which disables the check.
+func WithMaxChallengeAge(maxAge time.Duration) ClientOption {
+	return func(c *Client) error {
+		if maxAge < 0 {
+			return fmt.Errorf("max challenge age must not be negative")
+		}
+		c.MaxChallengeAge = maxAge
+		return nil
+	}
+}
+
+// WithClockSkew sets how much the clock of the Friendly Captcha API and the local clock may differ when checking the
+// age of a challenge, see WithMaxChallengeAge.
+//
+// This defaults to DefaultClockSkew.
+func WithClockSkew(skew time.Duration) ClientOption {
+	return func(c *Client) error {
+		if skew < 0 {
+			return fmt.Errorf("clock skew must not be negative")
+		}
+		c.ClockSkew = skew
+		return nil
+	}
+}
+
+// WithClock sets the function the client uses to get the current time when checking the age of a challenge. This is
+// mostly useful in tests.
+//
+// This defaults to time.Now.
+func WithClock(now func() time.Time) ClientOption {
+	return func(c *Client) error {
+		if now == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		c.now = now
+		return nil
+	}
+}
+
+// checkChallengeAge rejects a successfully verified result if its challenge is older than the maximum challenge age of
+// the call.
+func (frc *Client) checkChallengeAge(result *VerifyResult, cfg *callConfig) {
+	maxAge := cfg.maxChallengeAge
+	if maxAge <= 0 || !result.WasAbleToVerify() || !result.Success {
+		return
+	}
+	var timestamp time.Time
+	if result.response.Data != nil {
+		timestamp = result.response.Data.Challenge.Timestamp
+	}
+	if timestamp.IsZero() {
+		result.reject(fmt.Errorf("%w: challenge has no timestamp", ErrChallengeTooOld))
+		return
+	}
+
+	now := time.Now
+	if frc.now != nil {
+		now = frc.now
+	}
+	age := now().Sub(timestamp)
+	if age > maxAge+frc.ClockSkew {
+		result.reject(fmt.Errorf(
+			"%w: solved %s ago, the maximum is %s", ErrChallengeTooOld, age.Round(time.Second), maxAge,
+		))
+	}
+}
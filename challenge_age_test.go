@@ -0,0 +1,123 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var challengeTimestamp = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// respondWithTimestamp verifies every captcha response, with the given challenge timestamp.
+func respondWithTimestamp(timestamp string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"success":true,"data":{"event_id":"ev_test","challenge":{"timestamp":%q}}}`, timestamp)
+	}
+}
+
+func TestClientWithMaxChallengeAge(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithTimestamp(challengeTimestamp.Format(time.RFC3339)))
+	clock := &fakeClock{now: challengeTimestamp}
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithClock(clock.Now),
+		WithMaxChallengeAge(time.Minute),
+		WithClockSkew(10*time.Second),
+	)
+
+	tests := []struct {
+		age      time.Duration
+		accepted bool
+	}{
+		// The local clock is behind the clock of the API.
+		{age: -time.Hour, accepted: true},
+		{age: 0, accepted: true},
+		{age: time.Minute, accepted: true},
+		// Within the clock skew tolerance.
+		{age: time.Minute + 10*time.Second, accepted: true},
+		{age: time.Minute + 11*time.Second, accepted: false},
+		{age: time.Hour, accepted: false},
+	}
+
+	for _, tt := range tests {
+		clock.now = challengeTimestamp.Add(tt.age)
+		result := client.VerifyCaptchaResponse(context.Background(), "response")
+		assert.True(t, result.WasAbleToVerify())
+		assert.Equal(t, tt.accepted, result.ShouldAccept(), tt.age)
+		assert.Equal(t, !tt.accepted, result.IsChallengeTooOld(), tt.age)
+		assert.Equal(t, FailureNone, result.FailureClass())
+		if !tt.accepted {
+			assert.ErrorIs(t, result.RequestError(), ErrChallengeTooOld)
+			assert.False(t, result.Success)
+			assert.Equal(t, outcomeRejected, verifyOutcome(result))
+		}
+	}
+}
+
+func TestClientWithMaxChallengeAgePerCall(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithTimestamp(challengeTimestamp.Format(time.RFC3339)))
+	clock := &fakeClock{now: challengeTimestamp}
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithClock(clock.Now), WithMaxChallengeAge(time.Hour))
+	assert.Equal(t, DefaultClockSkew, client.ClockSkew)
+	clock.Advance(10 * time.Minute)
+
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").ShouldAccept())
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response", WithCallMaxChallengeAge(5*time.Minute))
+	assert.True(t, result.IsChallengeTooOld())
+	assert.ErrorContains(t, result.RequestError(), "solved 10m0s ago, the maximum is 5m0s")
+
+	// A per-call maximum age of 0 disables the check.
+	clock.Advance(time.Hour)
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response", WithCallMaxChallengeAge(0)).ShouldAccept())
+
+	// Without a client-wide maximum age, only calls with one are checked.
+	client.MaxChallengeAge = 0
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").ShouldAccept())
+	result = client.VerifyCaptchaResponse(context.Background(), "response", WithCallMaxChallengeAge(time.Hour))
+	assert.True(t, result.IsChallengeTooOld())
+}
+
+func TestClientWithMaxChallengeAgeMissingTimestamp(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithMaxChallengeAge(time.Hour))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "valid")
+	assert.True(t, result.IsChallengeTooOld())
+	assert.ErrorContains(t, result.RequestError(), "no timestamp")
+
+	// Responses the API rejected keep their error.
+	result = client.VerifyCaptchaResponse(context.Background(), "invalid")
+	assert.False(t, result.IsChallengeTooOld())
+	assert.Equal(t, ErrorCodeResponseInvalid, result.ErrorCode())
+}
+
+func TestClientWithMaxChallengeAgeDuringOutage(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithMaxChallengeAge(time.Minute))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.False(t, result.IsChallengeTooOld())
+	assert.True(t, result.ShouldAccept())
+}
+
+func TestChallengeAgeOptionsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, opt := range []ClientOption{WithMaxChallengeAge(-time.Second), WithClockSkew(-time.Second), WithClock(nil)} {
+		_, err := NewClient(WithAPIKey("test-key"), opt)
+		assert.Error(t, err)
+	}
+}
@@ -6,8 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // A ClientOption is a function that can be passed to NewClient to configure a new Client.
@@ -18,6 +25,14 @@ type Client struct {
 	APIKey      string
 	Sitekey     string
 	APIEndpoint string
+	// Endpoints is the ordered list of endpoints requests fail over between, see WithAPIEndpoints.
+	// Defaults to nil, which only uses APIEndpoint.
+	Endpoints []Endpoint
+	// EUDataResidency restricts requests to endpoints in the EU, see WithEUDataResidency.
+	EUDataResidency bool
+	// HedgeDelay is how long to wait for a response before sending a second request, see WithHedging.
+	// Defaults to 0, which disables hedging.
+	HedgeDelay time.Duration
 	// If Strict is set to true only strictly verified captcha response will be allowed.
 	// For example: if your server can not reach the Friendly Captcha endpoint, it will still advise to accept the response
 	// regardless.
@@ -26,8 +41,51 @@ type Client struct {
 	// could not be reached.
 	Strict bool
 	// The HTTP client to use for making requests to the Friendly Captcha API.
-	// Defaults to `http.DefaultClient`
+	// Defaults to a client with DefaultTimeout and a transport tuned for the Friendly Captcha API, see WithTransport.
 	HTTPClient *http.Client
+	// RetryPolicy configures how failed requests to the Friendly Captcha API are retried.
+	// The zero value (= the default) disables retries.
+	RetryPolicy RetryPolicy
+	// FailurePolicy decides per class of failure whether a captcha response that could not be verified is accepted.
+	// If it is nil, Strict decides: all failures are rejected in strict mode, and accepted otherwise.
+	FailurePolicy *FailurePolicy
+	// CircuitBreaker stops requests to the Friendly Captcha API while it is unavailable.
+	// Defaults to nil, which disables the circuit breaker.
+	CircuitBreaker *CircuitBreaker
+	// ConcurrencyLimiter bounds the number of calls to the Friendly Captcha API that are in flight at the same time.
+	// Defaults to nil, which doesn't limit them.
+	ConcurrencyLimiter *ConcurrencyLimiter
+	// Logger receives a structured log record for every call to the Friendly Captcha API, see WithLogger.
+	// Defaults to nil, which disables logging.
+	Logger *slog.Logger
+	// EventSink receives an Event for every call to the Friendly Captcha API, see WithEventSink.
+	// Defaults to nil, which disables events.
+	EventSink EventSink
+	// AllowedOrigins are the origins on which captcha responses are accepted, see WithAllowedOrigins.
+	// Defaults to nil, which allows all origins.
+	AllowedOrigins []string
+	// MaxChallengeAge is the maximum age of the challenge of an accepted captcha response, see WithMaxChallengeAge.
+	// Defaults to 0, which disables the check.
+	MaxChallengeAge time.Duration
+	// ClockSkew is how much older than MaxChallengeAge a challenge may be, to tolerate clocks that are out of sync.
+	ClockSkew time.Duration
+	// ReplayStore remembers submitted captcha responses to reject replays, see WithReplayProtection.
+	// Defaults to nil, which disables replay protection.
+	ReplayStore ReplayStore
+	// ReplayTTL is how long captcha responses are remembered by the ReplayStore.
+	ReplayTTL time.Duration
+
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	instruments    *telemetry
+	now            func() time.Time
+	endpointHealth *endpointHealth
+	coalescer      *verifyCoalescer
+	// releaseGauges stops reporting the gauges of the ConcurrencyLimiter for the client, see release.
+	releaseGauges func()
+	// transportConfig configures the HTTPClient if it was not set by WithHTTPClient.
+	transportConfig transportConfig
 }
 
 // The name of the form field that, by default, the widget will put the captcha response in.
@@ -44,13 +102,41 @@ var (
 
 // NewClient creates a new Friendly Captcha client with the given options.
 func NewClient(opts ...ClientOption) (*Client, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.APIKey == "" {
+		return nil, fmt.Errorf(
+			"you must set your Friendly Captcha API key using `WithAPIKey()` when creating a new client",
+		)
+	}
+
+	if err := c.validateEndpoints(); err != nil {
+		return nil, err
+	}
+
+	c.instruments = newTelemetry(c.tracerProvider, c.meterProvider, c.propagator)
+	if c.ConcurrencyLimiter != nil {
+		c.releaseGauges = c.instruments.observeLimiter(c.ConcurrencyLimiter)
+	}
+	c.endpointHealth = newEndpointHealth()
+
+	return c, nil
+}
+
+// newClient returns a client with the defaults and the given options applied, without validating it.
+func newClient(opts []ClientOption) (*Client, error) {
 	const (
 		defaultAPIEndpoint = globalAPIEndpoint
 	)
 
 	c := &Client{
-		HTTPClient:  http.DefaultClient,
-		APIEndpoint: defaultAPIEndpoint,
+		APIEndpoint:     defaultAPIEndpoint,
+		ClockSkew:       DefaultClockSkew,
+		now:             time.Now,
+		transportConfig: transportConfig{timeout: DefaultTimeout},
 	}
 
 	// Loop through each option
@@ -62,15 +148,24 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		}
 	}
 
-	if c.APIKey == "" {
-		return nil, fmt.Errorf(
-			"you must set your Friendly Captcha API key using `WithAPIKey()` when creating a new client",
-		)
+	if c.HTTPClient == nil {
+		httpClient, err := newHTTPClient(c.transportConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.HTTPClient = httpClient
 	}
-
 	return c, nil
 }
 
+// release releases the resources the client registered with shared objects, like the gauges of a shared
+// ConcurrencyLimiter. The client can still be used afterwards.
+func (frc *Client) release() {
+	if frc.releaseGauges != nil {
+		frc.releaseGauges()
+	}
+}
+
 // WithAPIKey sets the API key for the client.
 func WithAPIKey(apiKey string) ClientOption {
 	return func(c *Client) error {
@@ -87,12 +182,30 @@ func WithSitekey(sitekey string) ClientOption {
 	}
 }
 
+// WithHTTPClient sets the HTTP client that is used for requests to the Friendly Captcha API. It overrides earlier
+// WithTimeout, WithTransport, WithRootCAs, WithProxy and WithClientCertificate options.
+//
+// This defaults to a client with DefaultTimeout and a transport tuned for the Friendly Captcha API, see WithTransport.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return fmt.Errorf("HTTP client must not be nil")
+		}
+		c.HTTPClient = httpClient
+		return nil
+	}
+}
+
 // In strict mode only strictly verified captcha response are allowed. If your API key is invalid or your server can not reach the API endpoint all requests will be rejected.
 //
+// This is a preset for WithFailurePolicy: strict mode uses FailClosedPolicy, non-strict mode uses FailOpenPolicy. It
+// clears the policy set by an earlier WithFailurePolicy, so that the Strict field decides.
+//
 // This defaults to `false`.
 func WithStrictMode(strict bool) ClientOption {
 	return func(c *Client) error {
 		c.Strict = strict
+		c.FailurePolicy = nil
 		return nil
 	}
 }
@@ -110,6 +223,7 @@ func WithAPIEndpoint(apiEndpoint string) ClientOption {
 			return fmt.Errorf("apiEndpoint must not be empty")
 		}
 		c.APIEndpoint = apiEndpoint
+		c.Endpoints = nil
 		return nil
 	}
 }
@@ -141,28 +255,104 @@ func WithSiteverifyEndpoint(siteverifyEndpoint string) ClientOption {
 	}
 }
 
+// failurePolicy returns the FailurePolicy the client uses.
+func (frc *Client) failurePolicy() *FailurePolicy {
+	if frc.FailurePolicy != nil {
+		policy := *frc.FailurePolicy
+		return &policy
+	}
+	policy := strictFailurePolicy(frc.Strict)
+	return &policy
+}
+
 // VerifyCaptchaResponse takes a captcha response and verifies it with the Friendly Captcha API.
 // It returns a VerifyResult, which contains the result of the verification.
 //
 // On this result struct that will allow you to check if the verification could be performed, and whether
 // you should allow the user to proceed.
-func (frc *Client) VerifyCaptchaResponse(ctx context.Context, captchaResponse string) VerifyResult {
-	result := VerifyResult{}
+//
+// CallOptions override the configuration of the client for this call, e.g. WithCallSitekey or WithCallStrictMode.
+func (frc *Client) VerifyCaptchaResponse(ctx context.Context, captchaResponse string, opts ...CallOption) VerifyResult {
+	cfg := frc.newCallConfig(opts)
+	start := time.Now()
+	ctx, span := frc.telemetry().startSpan(ctx, spanNameVerify, operationVerify)
+	result := frc.verifyCaptchaResponse(ctx, captchaResponse, cfg)
+	elapsed := time.Since(start)
+	frc.telemetry().endVerify(ctx, span, result, elapsed)
+	frc.logVerify(ctx, result, elapsed)
+	frc.recordVerify(start, cfg.sitekey, result)
+	return result
+}
+
+func (frc *Client) verifyCaptchaResponse(ctx context.Context, captchaResponse string, cfg *callConfig) VerifyResult {
+	exchange, coalesced := frc.coalescer.do(ctx, cfg, captchaResponse, func(ctx context.Context) verifyExchange {
+		return frc.exchangeVerify(ctx, captchaResponse, cfg)
+	})
+	// The exchange may be shared with calls with other options, the options that decide the outcome are applied here.
+	result := frc.verifyResultOf(cfg, exchange)
+	result.coalesced = coalesced
+	return result
+}
+
+// verifyExchange is the outcome of the request to the siteverify endpoint for a captcha response. It can be shared
+// between calls with different configurations, see verifyResultOf.
+type verifyExchange struct {
+	call     apiCall
+	response VerifyResponse
+	err      error
+	replayed bool
+}
+
+// exchangeVerify sends the captcha response to the siteverify endpoint, unless it is a replay.
+func (frc *Client) exchangeVerify(ctx context.Context, captchaResponse string, cfg *callConfig) (exchange verifyExchange) {
+	key, replayed := frc.checkReplay(ctx, captchaResponse)
+	if replayed {
+		return verifyExchange{call: apiCall{statusCode: -1}, replayed: true}
+	}
+	defer func() { frc.forgetReplay(ctx, key, frc.verifyResultOf(cfg, exchange)) }()
+
 	reqBody := VerifyRequest{
 		Response: captchaResponse,
-		Sitekey:  frc.Sitekey,
+		Sitekey:  cfg.sitekey,
 	}
-	result.strict = frc.Strict
+	exchange.call, exchange.err = frc.postJSON(ctx, cfg, siteverifyPath, reqBody, &exchange.response)
+	return exchange
+}
+
+// verifyResultOf returns the result of a call with the given configuration.
+func (frc *Client) verifyResultOf(cfg *callConfig, exchange verifyExchange) VerifyResult {
+	result := VerifyResult{}
+	result.strict = cfg.strict
+	result.failurePolicy = cfg.failurePolicy
 	result.Status = -1
 
-	var vr VerifyResponse
-	statusCode, err := frc.postJSON(ctx, "/api/v2/captcha/siteverify", reqBody, &vr)
+	if exchange.replayed {
+		result.failure = FailureReplayed
+		result.err = ErrResponseReplayed
+		return result
+	}
+
+	call, err, vr := exchange.call, exchange.err, exchange.response
+	statusCode := call.statusCode
 	result.Status = statusCode
+	result.attempts = call.attempts
+	result.failure = classifyCall(call, err)
+	if vr.Error != nil {
+		result.errorCode = vr.Error.ErrorCode
+	}
 	if err != nil {
 		if errors.Is(err, errCreateRequest) {
 			result.err = fmt.Errorf("%w: %v", ErrCreatingVerificationRequest, err)
 			return result
 		}
+		if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrOverloaded) {
+			result.err = fmt.Errorf("%w: %w", ErrVerificationRequest, err)
+			return result
+		}
+		if errors.Is(err, ErrContextCanceled) {
+			result.err = err
+			return result
+		}
 		result.err = fmt.Errorf("%w: %v", ErrVerificationRequest, err)
 		return result
 	}
@@ -175,28 +365,66 @@ func (frc *Client) VerifyCaptchaResponse(ctx context.Context, captchaResponse st
 
 	result.response = vr
 	result.Success = vr.Success
+	frc.checkOrigin(&result, cfg)
+	frc.checkChallengeAge(&result, cfg)
 	return result
 }
 
 // RetrieveRiskIntelligence takes a risk intelligence token and retrieves the associated risk intelligence data from the Friendly Captcha API.
 // It returns a RiskIntelligenceRetrieveResult, which contains the risk intelligence data.
-func (frc *Client) RetrieveRiskIntelligence(ctx context.Context, token string) RiskIntelligenceRetrieveResult {
+//
+// CallOptions override the configuration of the client for this call, e.g. WithCallSitekey or WithCallTimeout.
+func (frc *Client) RetrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	opts ...CallOption,
+) RiskIntelligenceRetrieveResult {
+	cfg := frc.newCallConfig(opts)
+	start := time.Now()
+	ctx, span := frc.telemetry().startSpan(ctx, spanNameRetrieve, operationRetrieve)
+	result := frc.retrieveRiskIntelligence(ctx, token, cfg)
+	elapsed := time.Since(start)
+	frc.telemetry().endRetrieve(ctx, span, result, elapsed)
+	frc.logRetrieve(ctx, result, elapsed)
+	frc.recordRetrieve(start, cfg.sitekey, result)
+	return result
+}
+
+func (frc *Client) retrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	cfg *callConfig,
+) RiskIntelligenceRetrieveResult {
 	result := RiskIntelligenceRetrieveResult{}
 	reqBody := RiskIntelligenceRetrieveRequest{
 		Token:   token,
-		Sitekey: frc.Sitekey,
+		Sitekey: cfg.sitekey,
 	}
 	// We should never end up with this status code, unless we fail to be able to marshal the request body.
 	result.Status = -1
 
 	var retrieveResponse RiskIntelligenceRetrieveResponse
-	statusCode, err := frc.postJSON(ctx, "/api/v2/riskIntelligence/retrieve", reqBody, &retrieveResponse)
+	call, err := frc.postJSON(ctx, cfg, retrievePath, reqBody, &retrieveResponse)
+	statusCode := call.statusCode
 	result.Status = statusCode
+	result.attempts = call.attempts
+	result.failure = classifyCall(call, err)
+	if retrieveResponse.Error != nil {
+		result.errorCode = retrieveResponse.Error.ErrorCode
+	}
 	if err != nil {
 		if errors.Is(err, errCreateRequest) {
 			result.err = fmt.Errorf("%w: %v", ErrCreatingRiskIntelligenceRetrieveRequest, err)
 			return result
 		}
+		if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrOverloaded) {
+			result.err = fmt.Errorf("%w: %w", ErrRiskIntelligenceRetrieveRequest, err)
+			return result
+		}
+		if errors.Is(err, ErrContextCanceled) {
+			result.err = err
+			return result
+		}
 		result.err = fmt.Errorf("%w: %v", ErrRiskIntelligenceRetrieveRequest, err)
 		return result
 	}
@@ -217,37 +445,159 @@ func (frc *Client) RetrieveRiskIntelligence(ctx context.Context, token string) R
 	return result
 }
 
-func (frc *Client) postJSON(ctx context.Context, path string, requestBody any, responseBody any) (int, error) {
+// apiCall contains details about a, possibly retried, call to the Friendly Captcha API.
+type apiCall struct {
+	// statusCode of the last attempt, -1 if no response was received.
+	statusCode int
+	// attempts is the number of requests that were sent.
+	attempts int
+}
+
+// postJSON sends the request body to the given path of the API endpoint and decodes the response into the response
+// body. Failed requests are retried according to the client's RetryPolicy, and the outcome is recorded by the
+// client's CircuitBreaker. The call waits for a slot of the client's ConcurrencyLimiter first.
+func (frc *Client) postJSON(
+	ctx context.Context,
+	cfg *callConfig,
+	path string,
+	requestBody any,
+	responseBody any,
+) (apiCall, error) {
+	if !frc.CircuitBreaker.allow() {
+		return apiCall{statusCode: -1}, ErrCircuitOpen
+	}
+	// Time spent waiting for a slot counts against the call timeout.
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+	if err := frc.ConcurrencyLimiter.acquire(ctx); err != nil {
+		// Shedding load says nothing about the health of the API.
+		frc.CircuitBreaker.record(breakerIgnored)
+		return apiCall{statusCode: -1}, err
+	}
+	defer frc.ConcurrencyLimiter.release()
+
+	call, err := frc.postJSONWithRetries(ctx, cfg.header, path, requestBody, responseBody)
+	switch {
+	case errors.Is(err, errCreateRequest), errors.Is(err, ErrContextCanceled):
+		frc.CircuitBreaker.record(breakerIgnored)
+	case err != nil || call.statusCode >= 500:
+		frc.CircuitBreaker.record(breakerFailure)
+	default:
+		frc.CircuitBreaker.record(breakerSuccess)
+	}
+	return call, err
+}
+
+func (frc *Client) postJSONWithRetries(
+	ctx context.Context,
+	header http.Header,
+	path string,
+	requestBody any,
+	responseBody any,
+) (apiCall, error) {
+	call := apiCall{statusCode: -1}
+
 	reqBodyJSON, err := json.Marshal(requestBody)
 	if err != nil {
-		return -1, fmt.Errorf("%w: %v", errCreateRequest, err)
+		return call, fmt.Errorf("%w: %v", errCreateRequest, err)
+	}
+
+	var (
+		lastStatus = -1
+		lastBody   []byte
+		lastErr    error
+	)
+	maxAttempts := frc.RetryPolicy.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		resp, body, sent, err := frc.doRequestHedged(ctx, header, path, reqBodyJSON)
+		call.attempts += sent
+		if errors.Is(err, errCreateRequest) {
+			return call, err
+		}
+		// The earlier attempt may have reached the API although it failed, in which case this one is rejected as a
+		// duplicate. That says nothing about the captcha response, the earlier failure is the outcome of the call.
+		if attempt > 1 && err == nil && isDuplicateResponse(body) {
+			call.statusCode = lastStatus
+			if lastErr != nil {
+				return call, lastErr
+			}
+			return call, decodeResponseBody(lastBody, responseBody)
+		}
+
+		var wait time.Duration
+		if err != nil {
+			call.statusCode = -1
+			wait = frc.RetryPolicy.backoff(attempt)
+		} else {
+			call.statusCode = resp.StatusCode
+			if !isRetryableStatus(resp.StatusCode) {
+				return call, decodeResponseBody(body, responseBody)
+			}
+			wait = max(frc.RetryPolicy.backoff(attempt), retryAfter(resp))
+		}
+		lastStatus, lastBody, lastErr = call.statusCode, body, err
+
+		if attempt >= maxAttempts || !safeToRetry(path, resp, err) || ctx.Err() != nil || !waitForRetry(ctx, wait) {
+			// The call took longer than its timeout, see WithCallTimeout.
+			if cause := context.Cause(ctx); errors.Is(cause, errCallTimeout) {
+				return call, fmt.Errorf("error sending HTTP request: %w", cause)
+			}
+			// The caller gave up on the request, that says nothing about the availability of the API.
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return call, fmt.Errorf("%w: %w", ErrContextCanceled, ctxErr)
+			}
+			if err != nil {
+				return call, err
+			}
+			return call, decodeResponseBody(body, responseBody)
+		}
 	}
+}
 
+// doRequest sends a single request with the given extra headers to the endpoint and reads the full response body.
+func (frc *Client) doRequest(
+	ctx context.Context,
+	endpoint string,
+	header http.Header,
+	path string,
+	reqBodyJSON []byte,
+) (*http.Response, []byte, error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		frc.APIEndpoint+path,
+		endpoint+path,
 		bytes.NewReader(reqBodyJSON),
 	)
 	if err != nil {
-		return -1, fmt.Errorf("%w: %v", errCreateRequest, err)
+		return nil, nil, fmt.Errorf("%w: %v", errCreateRequest, err)
 	}
 
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Api-Key", frc.APIKey)
 	req.Header.Set("Frc-Sdk", fmt.Sprintf("friendly-captcha-go@%s", Version))
+	frc.telemetry().injectTraceContext(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := frc.HTTPClient.Do(req)
 	if err != nil {
-		return -1, fmt.Errorf("error sending HTTP request: %v", err)
+		return nil, nil, fmt.Errorf("error sending HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	statusCode := resp.StatusCode
-
-	if err := json.NewDecoder(resp.Body).Decode(responseBody); err != nil {
-		return statusCode, fmt.Errorf("error decoding response body: %v", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response body: %v", err)
 	}
+	return resp, body, nil
+}
 
-	return statusCode, nil
+func decodeResponseBody(body []byte, responseBody any) error {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(responseBody); err != nil {
+		return fmt.Errorf("error decoding response body: %v", err)
+	}
+	return nil
 }
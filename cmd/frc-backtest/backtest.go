@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+)
+
+// maxLineSize is the maximum size of a line in an archive.
+const maxLineSize = 16 << 20
+
+// maxExamples is the maximum number of event IDs listed per changed decision.
+const maxExamples = 5
+
+// archivedRecord is a line of an archive: a siteverify or retrieve response, which contain the risk intelligence in
+// their data, or any other object with a top-level risk_intelligence field.
+type archivedRecord struct {
+	EventID          string          `json:"event_id"`
+	RiskIntelligence json.RawMessage `json:"risk_intelligence"`
+	Data             *struct {
+		EventID          string          `json:"event_id"`
+		RiskIntelligence json.RawMessage `json:"risk_intelligence"`
+	} `json:"data"`
+}
+
+// decodeLine returns the risk intelligence and event ID of a line, it returns false if the line has no risk
+// intelligence.
+func decodeLine(line []byte) (friendlycaptcha.RiskIntelligenceData, string, bool, error) {
+	var data friendlycaptcha.RiskIntelligenceData
+	var record archivedRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return data, "", false, err
+	}
+
+	raw, eventID := record.RiskIntelligence, record.EventID
+	if record.Data != nil {
+		raw, eventID = record.Data.RiskIntelligence, record.Data.EventID
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return data, eventID, false, nil
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return data, eventID, false, err
+	}
+	return data, eventID, true, nil
+}
+
+type report struct {
+	// Evaluated is the number of records with risk intelligence, which were evaluated.
+	Evaluated int `json:"evaluated"`
+	// WithoutRiskIntelligence is the number of records without risk intelligence, which were skipped.
+	WithoutRiskIntelligence int `json:"without_risk_intelligence"`
+	// Malformed is the number of lines that could not be decoded, which were skipped.
+	Malformed int `json:"malformed"`
+
+	Candidate *summary `json:"candidate"`
+	Baseline  *summary `json:"baseline,omitempty"`
+	// Changes counts the records for which the candidate decided differently than the baseline.
+	Changes []*change `json:"changes,omitempty"`
+}
+
+type summary struct {
+	Decisions map[policy.Decision]int `json:"decisions"`
+	Rules     []*ruleHits             `json:"rules"`
+
+	rules map[string]*ruleHits
+}
+
+type ruleHits struct {
+	Name     string          `json:"name"`
+	Decision policy.Decision `json:"decision"`
+	Hits     int             `json:"hits"`
+	Rate     float64         `json:"rate"`
+}
+
+type change struct {
+	From     policy.Decision `json:"from"`
+	To       policy.Decision `json:"to"`
+	Count    int             `json:"count"`
+	Examples []string        `json:"examples,omitempty"`
+}
+
+func newSummary(p policy.Policy) *summary {
+	s := &summary{
+		Decisions: make(map[policy.Decision]int),
+		rules:     make(map[string]*ruleHits),
+	}
+	for _, rule := range p.Rules {
+		hits := &ruleHits{Name: rule.Name, Decision: rule.Decision}
+		s.Rules = append(s.Rules, hits)
+		s.rules[rule.Name] = hits
+	}
+	return s
+}
+
+func (s *summary) add(result policy.Result) {
+	s.Decisions[result.Decision]++
+	for _, rule := range result.Matched {
+		s.rules[rule.Name].Hits++
+	}
+}
+
+func (s *summary) finish(evaluated int) {
+	if evaluated == 0 {
+		return
+	}
+	for _, hits := range s.Rules {
+		hits.Rate = float64(hits.Hits) / float64(evaluated)
+	}
+}
+
+type backtest struct {
+	candidate policy.Policy
+	baseline  *policy.Policy
+	report    report
+	changes   map[[2]policy.Decision]*change
+	stderr    io.Writer
+}
+
+func (b *backtest) evaluate(data friendlycaptcha.RiskIntelligenceData, eventID string) {
+	b.report.Evaluated++
+	result := b.candidate.Evaluate(data)
+	b.report.Candidate.add(result)
+	if b.baseline == nil {
+		return
+	}
+
+	baselineResult := b.baseline.Evaluate(data)
+	b.report.Baseline.add(baselineResult)
+	if baselineResult.Decision == result.Decision {
+		return
+	}
+	key := [2]policy.Decision{baselineResult.Decision, result.Decision}
+	c, ok := b.changes[key]
+	if !ok {
+		c = &change{From: baselineResult.Decision, To: result.Decision}
+		b.changes[key] = c
+		b.report.Changes = append(b.report.Changes, c)
+	}
+	c.Count++
+	if eventID != "" && len(c.Examples) < maxExamples {
+		c.Examples = append(c.Examples, eventID)
+	}
+}
+
+// readArchive evaluates every line of the archive.
+func (b *backtest) readArchive(name string, r io.Reader) error {
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		data, eventID, ok, err := decodeLine(line)
+		if err != nil {
+			b.report.Malformed++
+			fmt.Fprintf(b.stderr, "%s:%d: skipping malformed line: %v\n", name, lineNo, err)
+			continue
+		}
+		if !ok {
+			b.report.WithoutRiskIntelligence++
+			continue
+		}
+		b.evaluate(data, eventID)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func (b *backtest) readArchiveFile(name string, stdin io.Reader) error {
+	if name == "-" {
+		return b.readArchive("stdin", stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return b.readArchive(name, f)
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("frc-backtest", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: frc-backtest -policy candidate.yaml [-baseline current.yaml] [-json] [archive.jsonl ...]")
+		flags.PrintDefaults()
+	}
+	candidatePath := flags.String("policy", "", "path of the candidate policy document (required)")
+	baselinePath := flags.String("baseline", "", "path of the baseline policy document to compare against")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *candidatePath == "" {
+		fmt.Fprintln(stderr, "frc-backtest: -policy is required")
+		flags.Usage()
+		return 2
+	}
+
+	candidate, err := policy.LoadFile(*candidatePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "frc-backtest: loading candidate policy: %v\n", err)
+		return 1
+	}
+	b := &backtest{
+		candidate: candidate,
+		changes:   make(map[[2]policy.Decision]*change),
+		stderr:    stderr,
+	}
+	b.report.Candidate = newSummary(candidate)
+	if *baselinePath != "" {
+		baseline, err := policy.LoadFile(*baselinePath)
+		if err != nil {
+			fmt.Fprintf(stderr, "frc-backtest: loading baseline policy: %v\n", err)
+			return 1
+		}
+		b.baseline = &baseline
+		b.report.Baseline = newSummary(baseline)
+	}
+
+	archives := flags.Args()
+	if len(archives) == 0 {
+		archives = []string{"-"}
+	}
+	for _, name := range archives {
+		if err := b.readArchiveFile(name, stdin); err != nil {
+			fmt.Fprintf(stderr, "frc-backtest: %v\n", err)
+			return 1
+		}
+	}
+
+	b.report.Candidate.finish(b.report.Evaluated)
+	if b.report.Baseline != nil {
+		b.report.Baseline.finish(b.report.Evaluated)
+	}
+	sort.Slice(b.report.Changes, func(i, j int) bool {
+		ci, cj := b.report.Changes[i], b.report.Changes[j]
+		if ci.Count != cj.Count {
+			return ci.Count > cj.Count
+		}
+		if ci.From != cj.From {
+			return ci.From < cj.From
+		}
+		return ci.To < cj.To
+	})
+
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(b.report); err != nil {
+			fmt.Fprintf(stderr, "frc-backtest: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	printReport(stdout, b.report)
+	return 0
+}
+
+func percentage(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+func printReport(w io.Writer, r report) {
+	fmt.Fprintf(w, "Evaluated %d records (%d without risk intelligence, %d malformed lines skipped)\n\n",
+		r.Evaluated, r.WithoutRiskIntelligence, r.Malformed)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if r.Baseline != nil {
+		fmt.Fprintln(tw, "DECISION\tCANDIDATE\t\tBASELINE\t\tDELTA")
+	} else {
+		fmt.Fprintln(tw, "DECISION\tCANDIDATE\t")
+	}
+	for d := policy.Allow; d <= policy.Deny; d++ {
+		n := r.Candidate.Decisions[d]
+		if r.Baseline != nil {
+			base := r.Baseline.Decisions[d]
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%+d\n",
+				d, n, percentage(n, r.Evaluated), base, percentage(base, r.Evaluated), n-base)
+		} else {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", d, n, percentage(n, r.Evaluated))
+		}
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CANDIDATE RULE\tDECISION\tHITS\tRATE")
+	for _, hits := range r.Candidate.Rules {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", hits.Name, hits.Decision, hits.Hits, percentage(hits.Hits, r.Evaluated))
+	}
+	_ = tw.Flush()
+
+	if r.Baseline == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	if len(r.Changes) == 0 {
+		fmt.Fprintln(w, "No decisions changed compared to the baseline.")
+		return
+	}
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BASELINE -> CANDIDATE\tRECORDS\tRATE\tEXAMPLE EVENT IDS")
+	for _, c := range r.Changes {
+		fmt.Fprintf(tw, "%s -> %s\t%d\t%s\t%s\n",
+			c.From, c.To, c.Count, percentage(c.Count, r.Evaluated), strings.Join(c.Examples, " "))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runBacktest(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestBacktestJSON(t *testing.T) {
+	t.Parallel()
+
+	code, stdout, stderr := runBacktest(t, "",
+		"-policy", "testdata/candidate.yaml", "-baseline", "testdata/baseline.yaml", "-json", "testdata/archive.jsonl",
+	)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "testdata/archive.jsonl:8: skipping malformed line")
+
+	var r report
+	require.NoError(t, json.Unmarshal([]byte(stdout), &r))
+	assert.Equal(t, 5, r.Evaluated)
+	assert.Equal(t, 2, r.WithoutRiskIntelligence)
+	assert.Equal(t, 1, r.Malformed)
+
+	assert.Equal(t, map[policy.Decision]int{
+		policy.Allow:     2,
+		policy.Challenge: 1,
+		policy.Review:    1,
+		policy.Deny:      1,
+	}, r.Candidate.Decisions)
+	assert.Equal(t, []*ruleHits{
+		{Name: "tor", Decision: policy.Deny, Hits: 1, Rate: 0.2},
+		{Name: "high-risk", Decision: policy.Review, Hits: 2, Rate: 0.4},
+		{Name: "hosting", Decision: policy.Challenge, Hits: 3, Rate: 0.6},
+	}, r.Candidate.Rules)
+
+	require.NotNil(t, r.Baseline)
+	assert.Equal(t, map[policy.Decision]int{policy.Allow: 4, policy.Deny: 1}, r.Baseline.Decisions)
+	assert.Equal(t, []*change{
+		{From: policy.Allow, To: policy.Challenge, Count: 1, Examples: []string{"ev_5"}},
+		{From: policy.Allow, To: policy.Review, Count: 1, Examples: []string{"ev_3"}},
+	}, r.Changes)
+}
+
+func TestBacktestText(t *testing.T) {
+	t.Parallel()
+
+	code, stdout, stderr := runBacktest(t, "",
+		"-policy", "testdata/candidate.yaml", "-baseline", "testdata/baseline.yaml", "testdata/archive.jsonl",
+	)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "Evaluated 5 records (2 without risk intelligence, 1 malformed lines skipped)")
+	assert.Regexp(t, `DECISION\s+CANDIDATE\s+BASELINE\s+DELTA`, stdout)
+	assert.Regexp(t, `allow\s+2\s+40\.0%\s+4\s+80\.0%\s+-2`, stdout)
+	assert.Regexp(t, `hosting\s+challenge\s+3\s+60\.0%`, stdout)
+	assert.Regexp(t, `allow -> review\s+1\s+20\.0%\s+ev_3`, stdout)
+
+	// Without a baseline there is nothing to compare.
+	code, stdout, stderr = runBacktest(t, "", "-policy", "testdata/baseline.yaml", "testdata/archive.jsonl")
+	require.Equal(t, 0, code, stderr)
+	assert.Regexp(t, `deny\s+1\s+20\.0%`, stdout)
+	assert.NotContains(t, stdout, "BASELINE")
+
+	// The same policy never changes a decision.
+	code, stdout, stderr = runBacktest(t, "",
+		"-policy", "testdata/baseline.yaml", "-baseline", "testdata/baseline.yaml", "testdata/archive.jsonl",
+	)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "No decisions changed compared to the baseline.")
+}
+
+func TestBacktestArchives(t *testing.T) {
+	t.Parallel()
+
+	archive, err := os.ReadFile("testdata/archive.jsonl")
+	require.NoError(t, err)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(archive)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	gzPath := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+	require.NoError(t, os.WriteFile(gzPath, compressed.Bytes(), 0o600))
+
+	// Multiple archives, including stdin and a compressed archive, are combined.
+	code, stdout, stderr := runBacktest(t, string(archive),
+		"-policy", "testdata/candidate.yaml", "-json", "-", gzPath,
+	)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "stdin:8: skipping malformed line")
+	assert.Contains(t, stderr, "archive.jsonl.gz:8: skipping malformed line")
+
+	var r report
+	require.NoError(t, json.Unmarshal([]byte(stdout), &r))
+	assert.Equal(t, 10, r.Evaluated)
+	assert.Equal(t, 4, r.WithoutRiskIntelligence)
+	assert.Equal(t, 2, r.Malformed)
+	assert.Nil(t, r.Baseline)
+	assert.Empty(t, r.Changes)
+
+	// Stdin is read if no archive is given.
+	code, stdout, _ = runBacktest(t, string(archive), "-policy", "testdata/candidate.yaml", "-json")
+	require.Equal(t, 0, code)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &r))
+	assert.Equal(t, 5, r.Evaluated)
+}
+
+func TestBacktestErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	invalidPolicy := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalidPolicy, []byte("rules:\n  - {name: a, when: {tor: true}, decision: block}\n"), 0o600))
+	invalidGzip := filepath.Join(dir, "archive.jsonl.gz")
+	require.NoError(t, os.WriteFile(invalidGzip, []byte(`{"event_id":"ev_1"}`), 0o600))
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "missing policy", args: []string{"testdata/archive.jsonl"}, code: 2, stderr: "-policy is required"},
+		{name: "unknown flag", args: []string{"-policy", "testdata/candidate.yaml", "-verbose"}, code: 2},
+		{
+			name:   "invalid policy",
+			args:   []string{"-policy", invalidPolicy, "testdata/archive.jsonl"},
+			code:   1,
+			stderr: `loading candidate policy: ` + invalidPolicy + `:2:44: unknown decision "block"`,
+		},
+		{
+			name:   "invalid baseline",
+			args:   []string{"-policy", "testdata/candidate.yaml", "-baseline", "testdata/missing.yaml"},
+			code:   1,
+			stderr: "loading baseline policy",
+		},
+		{
+			name:   "missing archive",
+			args:   []string{"-policy", "testdata/candidate.yaml", "testdata/missing.jsonl"},
+			code:   1,
+			stderr: "testdata/missing.jsonl",
+		},
+		{
+			name:   "invalid gzip",
+			args:   []string{"-policy", "testdata/candidate.yaml", invalidGzip},
+			code:   1,
+			stderr: invalidGzip + ": gzip: invalid header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			code, _, stderr := runBacktest(t, "", tt.args...)
+			assert.Equal(t, tt.code, code)
+			assert.Contains(t, stderr, tt.stderr)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Command frc-backtest replays archived risk intelligence against a policy, to find out how a policy change would
+// have affected past requests before deploying it.
+//
+// It reads JSONL archives where every line is a siteverify or risk intelligence retrieve response of the Friendly
+// Captcha API, or any JSON object with a top-level "risk_intelligence" field. Archives ending in .gz are decompressed.
+// The candidate policy is evaluated against the risk intelligence data of every line, and the decision counts and
+// rule hit rates are printed. With -baseline, the decisions are compared to those of the baseline policy.
+//
+// Usage:
+//
+//	frc-backtest -policy candidate.yaml [-baseline current.yaml] [-json] archive.jsonl [archive.jsonl.gz ...]
+//
+// Policies are policy documents as described in the documentation of the policy package. If no archive is given, or
+// the archive is "-", the archive is read from stdin. The command runs fully offline.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,175 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultResultCacheTTL is a sensible ttl for WithResponseCoalescing: long enough for a client retry or a double-click,
+// short enough to not defeat replay protection.
+const DefaultResultCacheTTL = 5 * time.Second
+
+// WithResponseCoalescing makes concurrent calls to `VerifyCaptchaResponse` with the same captcha response and sitekey
+// share a single request to the Friendly Captcha API, e.g. when a submit button is double-clicked. Without it, the
+// second call is rejected with `response_duplicate` (or as a replay, see WithReplayProtection).
+//
+// Verified results are also remembered for the cacheTTL, so that a retry shortly after gets the original outcome
+// instead of a duplicate error. This means that the same captcha response is accepted again within the cacheTTL, so
+// keep it short, e.g. DefaultResultCacheTTL. A cacheTTL of 0 only shares requests of concurrent calls. Results that
+// could not be verified are never remembered.
+//
+// Every call still gets its own result: call options that decide the outcome, like WithCallStrictMode,
+// WithCallExpectedOrigin and WithCallMaxChallengeAge, are applied per call, and `IsCoalesced()` returns true for
+// results that share the request of another call. The shared request is sent with the headers and timeout of the call
+// that started it. A call whose context is canceled or whose timeout (see WithCallTimeout) is exceeded stops waiting
+// for the shared request, which is only canceled once all calls sharing it stopped waiting.
+//
+// This defaults to disabled.
+func WithResponseCoalescing(cacheTTL time.Duration) ClientOption {
+	return func(c *Client) error {
+		if cacheTTL < 0 {
+			return fmt.Errorf("result cache ttl must not be negative")
+		}
+		c.coalescer = newVerifyCoalescer(cacheTTL)
+		return nil
+	}
+}
+
+// verifyCoalescer shares the exchanges of concurrent and recent calls with the same captcha response and sitekey. A nil
+// *verifyCoalescer doesn't share anything.
+type verifyCoalescer struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	flights map[string]*verifyFlight
+	cache   map[string]cachedExchange
+}
+
+// verifyFlight is an exchange that is in flight.
+type verifyFlight struct {
+	done     chan struct{}
+	exchange verifyExchange
+	// waiters is the number of calls that wait for the flight, it is canceled when it drops to 0.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type cachedExchange struct {
+	exchange  verifyExchange
+	expiresAt time.Time
+}
+
+func newVerifyCoalescer(ttl time.Duration) *verifyCoalescer {
+	return &verifyCoalescer{
+		ttl:     ttl,
+		now:     time.Now,
+		flights: make(map[string]*verifyFlight),
+		cache:   make(map[string]cachedExchange),
+	}
+}
+
+// coalesceKey returns the key of a captcha response and sitekey in the verifyCoalescer.
+func coalesceKey(sitekey, captchaResponse string) string {
+	sum := sha256.Sum256([]byte(sitekey + "\x00" + captchaResponse))
+	return hex.EncodeToString(sum[:])
+}
+
+// do returns the exchange of a call with the same sitekey and captcha response that is in flight or was cached, or
+// runs exchange. The second return value is true if the exchange of another call was used.
+//
+// The exchange runs with a context that is not canceled with the context of the call that started it, so that it can
+// be shared with other calls. Every call waits for it no longer than its own timeout.
+func (c *verifyCoalescer) do(
+	ctx context.Context,
+	cfg *callConfig,
+	captchaResponse string,
+	exchange func(ctx context.Context) verifyExchange,
+) (verifyExchange, bool) {
+	// Let the API reject empty responses with `response_missing`.
+	if c == nil || captchaResponse == "" {
+		return exchange(ctx), false
+	}
+	key := coalesceKey(cfg.sitekey, captchaResponse)
+
+	c.mu.Lock()
+	if cached, ok := c.cache[key]; ok {
+		if c.now().Before(cached.expiresAt) {
+			c.mu.Unlock()
+			return cached.exchange, true
+		}
+		delete(c.cache, key)
+	}
+	flight, shared := c.flights[key]
+	if shared {
+		flight.waiters++
+	} else {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		flight = &verifyFlight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.flights[key] = flight
+		go c.run(flightCtx, key, flight, exchange)
+	}
+	c.mu.Unlock()
+
+	waitCtx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+	select {
+	case <-flight.done:
+		return flight.exchange, shared
+	case <-waitCtx.Done():
+		c.mu.Lock()
+		flight.waiters--
+		if flight.waiters == 0 {
+			// Later calls start a new flight instead of joining the canceled one.
+			flight.cancel()
+			if c.flights[key] == flight {
+				delete(c.flights, key)
+			}
+		}
+		c.mu.Unlock()
+		err := fmt.Errorf("%w: %w", ErrContextCanceled, waitCtx.Err())
+		// The call took longer than its timeout, like in postJSONWithRetries.
+		if cause := context.Cause(waitCtx); errors.Is(cause, errCallTimeout) {
+			err = fmt.Errorf("error waiting for the request of another call: %w", cause)
+		}
+		return verifyExchange{call: apiCall{statusCode: -1}, err: err}, shared
+	}
+}
+
+func (c *verifyCoalescer) run(
+	ctx context.Context,
+	key string,
+	flight *verifyFlight,
+	exchange func(ctx context.Context) verifyExchange,
+) {
+	defer flight.cancel()
+	result := exchange(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A canceled flight was already removed, and may have been replaced by a new one, see do.
+	if c.flights[key] == flight {
+		delete(c.flights, key)
+		if c.ttl > 0 && result.err == nil && result.call.statusCode == http.StatusOK {
+			c.cache[key] = cachedExchange{exchange: result, expiresAt: c.now().Add(c.ttl)}
+			time.AfterFunc(c.ttl, func() { c.expire(key) })
+		}
+	}
+	flight.exchange = result
+	close(flight.done)
+}
+
+// expire removes the cached exchange for the key if it expired.
+func (c *verifyCoalescer) expire(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.cache[key]; ok && !c.now().Before(cached.expiresAt) {
+		delete(c.cache, key)
+	}
+}
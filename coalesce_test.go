@@ -0,0 +1,233 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForWaiters blocks until n calls wait for the flight of the captcha response.
+func waitForWaiters(t *testing.T, client *Client, sitekey, captchaResponse string, n int) {
+	t.Helper()
+
+	key := coalesceKey(sitekey, captchaResponse)
+	require.Eventually(t, func() bool {
+		client.coalescer.mu.Lock()
+		defer client.coalescer.mu.Unlock()
+		flight, ok := client.coalescer.flights[key]
+		return ok && flight.waiters == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestClientCoalescesConcurrentCalls(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server, requests := newTestServer(t, respondWhenReleased(release, respondSuccess), respondDuplicate)
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithReplayProtection(NewMemoryReplayStore(0), time.Minute),
+		WithResponseCoalescing(0),
+	)
+
+	const calls = 5
+	results := make([]VerifyResult, calls)
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = client.VerifyCaptchaResponse(context.Background(), "response")
+		}()
+	}
+	waitForWaiters(t, client, "", "response", calls)
+	close(release)
+	wg.Wait()
+
+	coalesced := 0
+	for _, result := range results {
+		assert.True(t, result.ShouldAccept())
+		assert.True(t, result.WasAbleToVerify())
+		assert.False(t, result.IsReplayed())
+		if result.IsCoalesced() {
+			coalesced++
+		}
+	}
+	assert.Equal(t, calls-1, coalesced)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Without a cache, later calls are not coalesced.
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.IsReplayed())
+	assert.False(t, result.IsCoalesced())
+}
+
+func TestClientCoalescingCache(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, respondSuccess, respondDuplicate)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithResponseCoalescing(time.Minute))
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	client.coalescer.now = clock.Now
+	ctx := context.Background()
+
+	result := client.VerifyCaptchaResponse(ctx, "response")
+	assert.True(t, result.ShouldAccept())
+	assert.False(t, result.IsCoalesced())
+
+	// A retry gets the original outcome, but its own call options.
+	result = client.VerifyCaptchaResponse(ctx, "response", WithCallExpectedOrigin("https://example.com"))
+	assert.True(t, result.IsCoalesced())
+	assert.True(t, result.WasAbleToVerify())
+	assert.True(t, result.IsOriginNotAllowed())
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Other sitekeys and responses are not coalesced.
+	assert.False(t, client.VerifyCaptchaResponse(ctx, "response", WithCallSitekey("other")).IsCoalesced())
+	assert.False(t, client.VerifyCaptchaResponse(ctx, "other").IsCoalesced())
+	assert.Equal(t, int32(3), requests.Load())
+
+	clock.Advance(time.Minute)
+	result = client.VerifyCaptchaResponse(ctx, "response")
+	assert.False(t, result.IsCoalesced())
+	assert.Equal(t, ErrorCodeResponseDuplicate, result.ErrorCode())
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestClientCoalescingCacheSkipsFailures(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable), respondSuccess)
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		WithResponseCoalescing(time.Minute),
+	)
+
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "response").WasAbleToVerify())
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.WasAbleToVerify())
+	assert.False(t, result.IsCoalesced())
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestClientCoalescingCallOptions(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	unavailable := respondWithStatus(http.StatusServiceUnavailable)
+	server, requests := newTestServer(t, respondWhenReleased(release, unavailable))
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		WithResponseCoalescing(0),
+	)
+
+	first := make(chan VerifyResult, 1)
+	go func() { first <- client.VerifyCaptchaResponse(context.Background(), "response") }()
+	waitForWaiters(t, client, "", "response", 1)
+	strict := make(chan VerifyResult, 1)
+	go func() {
+		strict <- client.VerifyCaptchaResponse(context.Background(), "response", WithCallStrictMode(true))
+	}()
+	waitForWaiters(t, client, "", "response", 2)
+	close(release)
+
+	// The failure is shared, but every call applies its own failure policy.
+	result := <-first
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.True(t, result.ShouldAccept())
+	result = <-strict
+	assert.True(t, result.IsCoalesced())
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.False(t, result.ShouldAccept())
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClientCoalescingCallTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server, requests := newTestServer(t, respondWhenReleased(release, respondSuccess))
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithResponseCoalescing(0))
+
+	first := make(chan VerifyResult, 1)
+	go func() { first <- client.VerifyCaptchaResponse(context.Background(), "response") }()
+	waitForWaiters(t, client, "", "response", 1)
+
+	// A call that joins the request of another call doesn't wait longer than its own timeout.
+	start := time.Now()
+	result := client.VerifyCaptchaResponse(context.Background(), "response", WithCallTimeout(20*time.Millisecond))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, result.IsCoalesced())
+	assert.False(t, result.IsContextCanceled())
+	assert.Equal(t, FailureTimeout, result.FailureClass())
+
+	// The shared request continues for the other call.
+	close(release)
+	result = <-first
+	assert.True(t, result.WasAbleToVerify())
+	assert.True(t, result.ShouldAccept())
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClientCoalescingCanceledCalls(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	server, _ := newTestServer(t, respondWhenReleased(release, respondSuccess))
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithResponseCoalescing(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan VerifyResult, 2)
+	for range 2 {
+		go func() { results <- client.VerifyCaptchaResponse(ctx, "response") }()
+	}
+	waitForWaiters(t, client, "", "response", 2)
+	cancel()
+
+	for range 2 {
+		result := <-results
+		assert.False(t, result.WasAbleToVerify())
+		assert.ErrorIs(t, result.RequestError(), ErrContextCanceled)
+	}
+	// The shared request is canceled once no call waits for it anymore.
+	require.Eventually(t, func() bool {
+		client.coalescer.mu.Lock()
+		defer client.coalescer.mu.Unlock()
+		return len(client.coalescer.flights) == 0
+	}, time.Second, time.Millisecond)
+
+	_, err := NewClient(WithAPIKey("test-key"), WithResponseCoalescing(-time.Second))
+	assert.Error(t, err)
+}
+
+func TestClientCoalescingAfterCanceledCall(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	server, requests := newTestServer(t, respondWhenReleased(release, respondSuccess), respondSuccess)
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithResponseCoalescing(DefaultResultCacheTTL),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := client.VerifyCaptchaResponse(ctx, "response")
+	assert.ErrorIs(t, result.RequestError(), ErrContextCanceled)
+
+	// A call right after doesn't join the canceled request.
+	result = client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.NoError(t, result.RequestError())
+	assert.True(t, result.WasAbleToVerify())
+	assert.False(t, result.IsCoalesced())
+	assert.Equal(t, int32(2), requests.Load())
+}
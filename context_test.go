@@ -0,0 +1,90 @@
+package friendlycaptcha_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextCancellationIsRejected(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	t.Cleanup(server.Close)
+	server.OnVerify("slow", friendlycaptchatest.VerifySuccess().WithDelay(5*time.Second))
+	server.OnRetrieve("slow", friendlycaptchatest.RetrieveSuccess(friendlycaptcha.RiskIntelligenceData{}).WithDelay(5*time.Second))
+
+	client := server.Client()
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		result := client.VerifyCaptchaResponse(ctx, "slow")
+		assert.True(t, result.IsContextCanceled())
+		assert.False(t, result.IsRequestError())
+		assert.False(t, result.WasAbleToVerify())
+		assert.False(t, result.ShouldAccept(), "non-strict mode must not accept canceled verifications")
+		assert.ErrorIs(t, result.RequestError(), context.Canceled)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		result := client.VerifyCaptchaResponse(ctx, "slow")
+		assert.True(t, result.IsContextCanceled())
+		assert.False(t, result.ShouldAccept())
+		assert.ErrorIs(t, result.RequestError(), context.DeadlineExceeded)
+
+		riResult := client.RetrieveRiskIntelligence(ctx, "slow")
+		assert.True(t, riResult.IsContextCanceled())
+		assert.False(t, riResult.IsRequestError())
+	})
+
+	t.Run("already canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result := client.VerifyCaptchaResponse(ctx, "slow")
+		assert.True(t, result.IsContextCanceled())
+		assert.False(t, result.ShouldAccept())
+	})
+}
+
+func TestHTTPClientTimeoutFailsOpen(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("slow", friendlycaptchatest.VerifySuccess().WithDelay(5*time.Second))
+
+	client := server.Client()
+	client.HTTPClient = &http.Client{Timeout: 20 * time.Millisecond}
+
+	result := client.VerifyCaptchaResponse(context.Background(), "slow")
+	assert.False(t, result.IsContextCanceled())
+	assert.True(t, result.IsRequestError())
+	assert.True(t, result.ShouldAccept(), "an unresponsive API is a genuine outage")
+}
+
+func TestNewVerifyResultWithContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	err := errors.Join(friendlycaptcha.ErrContextCanceled, context.Canceled)
+	result := friendlycaptcha.NewVerifyResult(friendlycaptcha.VerifyResponse{}, -1, false, err)
+	assert.True(t, result.IsContextCanceled())
+	assert.False(t, result.ShouldAccept())
+}
@@ -0,0 +1,213 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultEndpointCooldown is how long an endpoint is considered unhealthy after a failed request, see
+// WithAPIEndpoints.
+const DefaultEndpointCooldown = 30 * time.Second
+
+// Endpoint is a base URL of the Friendly Captcha API, see WithAPIEndpoints.
+type Endpoint struct {
+	// URL is the base URL without a path, e.g. "https://eu.frcapi.com".
+	URL string
+	// EU is true if the endpoint processes data in the EU, see WithEUDataResidency.
+	EU bool
+}
+
+var (
+	// GlobalEndpoint is the global endpoint of the Friendly Captcha API.
+	GlobalEndpoint = Endpoint{URL: globalAPIEndpoint}
+	// EUEndpoint is the endpoint of the Friendly Captcha API that processes data in the EU.
+	EUEndpoint = Endpoint{URL: euAPIEndpoint, EU: true}
+)
+
+// WithAPIEndpoints sets an ordered list of endpoints, e.g. `WithAPIEndpoints(EUEndpoint, GlobalEndpoint)`. Requests
+// are sent to the first healthy endpoint, and fail over to the next one on connection errors and 5xx responses. An
+// endpoint that failed is unhealthy for DefaultEndpointCooldown, during which it is only tried after all healthy
+// endpoints. The URL may also be one of the shorthands "global" or "eu".
+//
+// Like retries (see WithRetryPolicy), failing over after a connection error may send a captcha response that the
+// failed endpoint already verified, in which case the next endpoint rejects it with `response_duplicate`. Then the
+// failure of the earlier endpoint is used instead. `RetrieveRiskIntelligence` only fails over if the token was
+// certainly not used, i.e. if no connection could be established.
+//
+// This overrides WithAPIEndpoint, APIEndpoint is set to the first endpoint. By default only APIEndpoint is used.
+func WithAPIEndpoints(endpoints ...Endpoint) ClientOption {
+	return func(c *Client) error {
+		if len(endpoints) == 0 {
+			return fmt.Errorf("endpoints must not be empty")
+		}
+		resolved := make([]Endpoint, len(endpoints))
+		for i, endpoint := range endpoints {
+			switch endpoint.URL {
+			case "global":
+				endpoint = GlobalEndpoint
+			case "eu", euAPIEndpoint:
+				endpoint = EUEndpoint
+			case "":
+				return fmt.Errorf("endpoint URL must not be empty")
+			}
+			resolved[i] = endpoint
+		}
+		c.Endpoints = resolved
+		c.APIEndpoint = resolved[0].URL
+		return nil
+	}
+}
+
+// WithEUDataResidency only sends requests to endpoints that process data in the EU: EUEndpoint, and endpoints passed
+// to WithAPIEndpoints with EU set. Requests never fail over to other endpoints. NewClient returns an error if no such
+// endpoint is configured.
+//
+// This defaults to false.
+func WithEUDataResidency(required bool) ClientOption {
+	return func(c *Client) error {
+		c.EUDataResidency = required
+		return nil
+	}
+}
+
+// endpoints returns the endpoints the client may send requests to, in the configured order.
+func (frc *Client) endpoints() []Endpoint {
+	endpoints := frc.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []Endpoint{{URL: frc.APIEndpoint, EU: frc.APIEndpoint == euAPIEndpoint}}
+	}
+	if !frc.EUDataResidency {
+		return endpoints
+	}
+	eu := make([]Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.EU {
+			eu = append(eu, endpoint)
+		}
+	}
+	return eu
+}
+
+// errNoEUEndpoint means that EU data residency is required, but no EU endpoint is configured.
+var errNoEUEndpoint = errors.New("EU data residency requires an EU endpoint, e.g. `WithAPIEndpoint(\"eu\")`")
+
+// validateEndpoints returns an error if the client has no endpoint it may send requests to.
+func (frc *Client) validateEndpoints() error {
+	if len(frc.endpoints()) == 0 {
+		return errNoEUEndpoint
+	}
+	return nil
+}
+
+// HealthyEndpoints returns the endpoints that are currently considered healthy, which is useful for monitoring.
+func (frc *Client) HealthyEndpoints() []Endpoint {
+	var healthy []Endpoint
+	for _, endpoint := range frc.endpoints() {
+		if frc.endpointHealth.healthy(endpoint.URL) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	return healthy
+}
+
+// doRequestWithFailover sends the request to the endpoints of the client, healthy ones first, until one responds
+// without a 5xx status. It starts at the endpoint with the given index in that order, wrapping around. It returns the
+// last response or error, and the number of requests it sent.
+func (frc *Client) doRequestWithFailover(
+	ctx context.Context,
+	first int,
+	header http.Header,
+	path string,
+	reqBodyJSON []byte,
+) (*http.Response, []byte, int, error) {
+	endpoints := frc.endpointHealth.order(frc.endpoints())
+	if len(endpoints) == 0 {
+		// NewClient rejects this, but EUDataResidency may have been set on a Client that was not created by it.
+		return nil, nil, 0, fmt.Errorf("%w: %w", errCreateRequest, errNoEUEndpoint)
+	}
+	if first %= len(endpoints); first > 0 {
+		endpoints = append(endpoints[first:len(endpoints):len(endpoints)], endpoints[:first]...)
+	}
+	var (
+		resp *http.Response
+		body []byte
+		err  error
+	)
+	for i, endpoint := range endpoints {
+		prevResp, prevBody, prevErr := resp, body, err
+		resp, body, err = frc.doRequest(ctx, endpoint.URL, header, path, reqBodyJSON)
+		if errors.Is(err, errCreateRequest) || ctx.Err() != nil {
+			return resp, body, i + 1, err
+		}
+		failed := err != nil || resp.StatusCode >= 500
+		frc.endpointHealth.record(endpoint.URL, failed)
+		// The previous endpoint may have verified the captcha response before it failed, see postJSONWithRetries.
+		if i > 0 && !failed && isDuplicateResponse(body) {
+			return prevResp, prevBody, i + 1, prevErr
+		}
+		if !failed || !safeToRetry(path, resp, err) {
+			return resp, body, i + 1, err
+		}
+	}
+	return resp, body, len(endpoints), err
+}
+
+// endpointHealth tracks which endpoints failed recently. A nil *endpointHealth considers all endpoints healthy.
+type endpointHealth struct {
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu             sync.Mutex
+	unhealthyUntil map[string]time.Time
+}
+
+func newEndpointHealth() *endpointHealth {
+	return &endpointHealth{
+		cooldown:       DefaultEndpointCooldown,
+		now:            time.Now,
+		unhealthyUntil: make(map[string]time.Time),
+	}
+}
+
+func (h *endpointHealth) healthy(url string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.now().Before(h.unhealthyUntil[url])
+}
+
+// order returns the healthy endpoints followed by the unhealthy ones, both in the given order.
+func (h *endpointHealth) order(endpoints []Endpoint) []Endpoint {
+	if h == nil || len(endpoints) < 2 {
+		return endpoints
+	}
+	ordered := make([]Endpoint, 0, len(endpoints))
+	var unhealthy []Endpoint
+	for _, endpoint := range endpoints {
+		if h.healthy(endpoint.URL) {
+			ordered = append(ordered, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(ordered, unhealthy...)
+}
+
+func (h *endpointHealth) record(url string, failed bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if failed {
+		h.unhealthyUntil[url] = h.now().Add(h.cooldown)
+	} else {
+		delete(h.unhealthyUntil, url)
+	}
+}
@@ -0,0 +1,192 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientFailover(t *testing.T) {
+	t.Parallel()
+
+	primary, primaryRequests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable), respondSuccess)
+	secondary, secondaryRequests := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoints(
+		Endpoint{URL: primary.URL},
+		Endpoint{URL: secondary.URL},
+	))
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	client.endpointHealth.now = clock.Now
+	assert.Equal(t, primary.URL, client.APIEndpoint)
+	ctx := context.Background()
+
+	result := client.VerifyCaptchaResponse(ctx, "response")
+	assert.True(t, result.WasAbleToVerify())
+	assert.Equal(t, 2, result.Attempts())
+	assert.Equal(t, []Endpoint{{URL: secondary.URL}}, client.HealthyEndpoints())
+
+	// The primary is skipped while it is unhealthy.
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "response").WasAbleToVerify())
+	assert.Equal(t, int32(1), primaryRequests.Load())
+	assert.Equal(t, int32(2), secondaryRequests.Load())
+
+	// And tried again after the cooldown.
+	clock.Advance(DefaultEndpointCooldown)
+	assert.True(t, client.VerifyCaptchaResponse(ctx, "response").WasAbleToVerify())
+	assert.Equal(t, int32(2), primaryRequests.Load())
+	assert.Equal(t, int32(2), secondaryRequests.Load())
+	assert.Len(t, client.HealthyEndpoints(), 2)
+}
+
+func TestClientFailoverConnectionError(t *testing.T) {
+	t.Parallel()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	secondary, _ := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoints(Endpoint{URL: closed.URL}, Endpoint{URL: secondary.URL}))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.ShouldAccept())
+	assert.True(t, result.WasAbleToVerify())
+	assert.Equal(t, 2, result.Attempts())
+}
+
+func TestClientFailoverAllEndpointsFail(t *testing.T) {
+	t.Parallel()
+
+	primary, primaryRequests := newTestServer(t, respondWithStatus(http.StatusBadGateway))
+	secondary, secondaryRequests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	client := newTestClient(t,
+		WithAPIEndpoints(Endpoint{URL: primary.URL}, Endpoint{URL: secondary.URL}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+	)
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.Equal(t, http.StatusServiceUnavailable, result.Status, "the last response is kept")
+	assert.Equal(t, 4, result.Attempts())
+	assert.Equal(t, int32(2), primaryRequests.Load())
+	assert.Equal(t, int32(2), secondaryRequests.Load())
+	assert.Empty(t, client.HealthyEndpoints())
+}
+
+func TestClientNoFailoverOnClientError(t *testing.T) {
+	t.Parallel()
+
+	primary, _ := newTestServer(t, respondWithStatus(http.StatusUnauthorized))
+	secondary, secondaryRequests := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoints(Endpoint{URL: primary.URL}, Endpoint{URL: secondary.URL}))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.IsErrorDueToClientError())
+	assert.Equal(t, int32(0), secondaryRequests.Load())
+	assert.Len(t, client.HealthyEndpoints(), 2)
+}
+
+func TestClientEUDataResidency(t *testing.T) {
+	t.Parallel()
+
+	eu, euRequests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable), respondSuccess)
+	global, globalRequests := newTestServer(t)
+	client := newTestClient(t,
+		WithAPIEndpoints(Endpoint{URL: global.URL}, Endpoint{URL: eu.URL, EU: true}),
+		WithEUDataResidency(true),
+	)
+
+	// Requests never leave the EU, also when the EU endpoint fails.
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").WasAbleToVerify())
+	assert.Equal(t, int32(2), euRequests.Load())
+	assert.Equal(t, int32(0), globalRequests.Load())
+	assert.Equal(t, []Endpoint{{URL: eu.URL, EU: true}}, client.HealthyEndpoints())
+}
+
+func TestClientEUDataResidencyWithoutEUEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoint(server.URL))
+	// NewClient rejects this configuration, but the field can still be set afterwards.
+	client.EUDataResidency = true
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureCreatingRequest, result.FailureClass())
+	assert.ErrorContains(t, result.RequestError(), "requires an EU endpoint")
+	assert.False(t, result.ShouldAccept())
+	assert.False(t, client.RetrieveRiskIntelligence(context.Background(), "token").WasAbleToRetrieve())
+	assert.Equal(t, int32(0), requests.Load())
+}
+
+func TestEndpointOptions(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoints(Endpoint{URL: "eu"}, Endpoint{URL: "global"}))
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{EUEndpoint, GlobalEndpoint}, client.Endpoints)
+	assert.Equal(t, euAPIEndpoint, client.APIEndpoint)
+
+	// WithAPIEndpoint replaces the list.
+	client, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoints(EUEndpoint, GlobalEndpoint), WithAPIEndpoint("global"))
+	require.NoError(t, err)
+	assert.Nil(t, client.Endpoints)
+	assert.Equal(t, []Endpoint{GlobalEndpoint}, client.HealthyEndpoints())
+
+	_, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoint("eu"), WithEUDataResidency(true))
+	assert.NoError(t, err)
+	_, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoint("https://eu.frcapi.com"), WithEUDataResidency(true))
+	assert.NoError(t, err)
+	_, err = NewClient(WithAPIKey("test-key"), WithEUDataResidency(true))
+	assert.ErrorContains(t, err, "requires an EU endpoint")
+	_, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoints())
+	assert.Error(t, err)
+	_, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoints(Endpoint{}))
+	assert.Error(t, err)
+}
+
+func TestClientFailoverDuplicate(t *testing.T) {
+	t.Parallel()
+
+	// The primary verified the response before it failed, so the secondary rejects it as a duplicate.
+	primary, _ := newTestServer(t, respondWithStatus(http.StatusBadGateway))
+	secondary, secondaryRequests := newTestServer(t, respondDuplicate)
+	client := newTestClient(t, WithAPIEndpoints(Endpoint{URL: primary.URL}, Endpoint{URL: secondary.URL}))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.Equal(t, http.StatusBadGateway, result.Status)
+	assert.True(t, result.ShouldAccept())
+	assert.Equal(t, 2, result.Attempts())
+	assert.Equal(t, int32(1), secondaryRequests.Load())
+}
+
+func TestClientFailoverRetrieve(t *testing.T) {
+	t.Parallel()
+
+	// The primary may have used the token.
+	primary, _ := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	secondary, secondaryRequests := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoints(Endpoint{URL: primary.URL}, Endpoint{URL: secondary.URL}))
+
+	result := client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.Equal(t, FailureServerError, result.FailureClass())
+	assert.Equal(t, 1, result.Attempts())
+	assert.Equal(t, int32(0), secondaryRequests.Load())
+
+	// The token was not used if no connection could be established.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	client = newTestClient(t, WithAPIEndpoints(Endpoint{URL: closed.URL}, Endpoint{URL: secondary.URL}))
+	result = client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.True(t, result.WasAbleToRetrieve())
+	assert.Equal(t, 2, result.Attempts())
+}
@@ -27,6 +27,43 @@ var ErrRiskIntelligenceRetrieveFailedDueToClientError = errors.New(
 	"risk intelligence retrieve request failed due to a client error (check your credentials)",
 )
 
+// The request was not sent because the circuit breaker is open: the Friendly Captcha API failed too often recently.
+// Results with this error are treated like any other failure to talk to the Friendly Captcha API.
+var ErrCircuitOpen = errors.New("circuit breaker is open, not sending request to Friendly Captcha API")
+
+// The request was not sent because the ConcurrencyLimiter had no free slot within its queue timeout: too many calls to
+// the Friendly Captcha API were in flight. Results with this error are treated like any other failure to talk to the
+// Friendly Captcha API.
+var ErrOverloaded = errors.New("too many requests in flight, not sending request to Friendly Captcha API")
+
+// The context passed to the Client was canceled or its deadline was exceeded before the request to the Friendly
+// Captcha API completed. This is not treated as a failure of the Friendly Captcha API: `ShouldAccept` always returns
+// false for results with this error, also when strict mode is disabled. Otherwise an attacker that can make your
+// server cancel requests could get their captcha responses accepted without verification.
+//
+// Note that a timeout of the HTTP client itself (see `http.Client.Timeout`) is not a context cancellation.
+var ErrContextCanceled = errors.New("request to Friendly Captcha API was canceled by the caller")
+
+// The captcha response was already submitted before, see WithReplayProtection. It was not sent to the Friendly Captcha
+// API again. `ShouldAccept` always returns false for results with this error.
+var ErrResponseReplayed = errors.New("captcha response was already submitted before")
+
+// The captcha response was verified successfully, but the challenge was solved on an origin that is not allowed, see
+// WithAllowedOrigins. `ShouldAccept` returns false for results with this error.
+var ErrOriginNotAllowed = errors.New("captcha was solved on an origin that is not allowed")
+
+// The captcha response was verified successfully, but the challenge was solved too long ago, see WithMaxChallengeAge.
+// `ShouldAccept` returns false for results with this error.
+var ErrChallengeTooOld = errors.New("captcha challenge was solved too long ago")
+
+// The request has no captcha response, see Client.Middleware. It was not sent to the Friendly Captcha API.
+// `ShouldAccept` always returns false for results with this error.
+var ErrResponseMissing = errors.New("request has no captcha response")
+
+// The Registry has no tenant for the request, see Registry.Middleware. `ShouldAccept` always returns false for results
+// with this error.
+var ErrUnknownTenant = errors.New("no tenant configured for the request")
+
 // ErrorCode is an error code that the Friendly Captcha API can return.
 type ErrorCode string
 
@@ -0,0 +1,100 @@
+package friendlycaptcha
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event describes a call to `VerifyCaptchaResponse` or `RetrieveRiskIntelligence`, it is passed to the EventSink of
+// the client to keep an audit trail of captcha decisions.
+//
+// Encoded as JSON, an event has the same top-level `event_id` and `risk_intelligence` fields as a response of the
+// Friendly Captcha API, so archived events can be replayed against a policy with the frc-backtest command.
+type Event struct {
+	// Time is when the call started.
+	Time time.Time `json:"time"`
+	// Operation is "siteverify" or "risk_intelligence_retrieve".
+	Operation string `json:"operation"`
+	// Sitekey is the sitekey the call was made for, if any.
+	Sitekey string `json:"sitekey,omitempty"`
+	// Outcome is the outcome of the call: "accepted", "rejected", "failed_open" or "failed_closed" for siteverify, and
+	// "valid", "invalid" or "failed" for risk intelligence retrieval.
+	Outcome string `json:"outcome"`
+	// Status is the HTTP status code of the response from the Friendly Captcha API, -1 if no response was received.
+	Status int `json:"status"`
+	// ErrorCode is the error code the Friendly Captcha API responded with, if any.
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+	// FailureClass is why the call could not be completed, if it could not.
+	FailureClass FailureClass `json:"failure_class,omitempty"`
+	// EventID is the event ID the Friendly Captcha API responded with, if any.
+	EventID string `json:"event_id,omitempty"`
+	// Origin is the origin of the site where the challenge was solved or the risk intelligence token was generated.
+	Origin string `json:"origin,omitempty"`
+	// RiskIntelligence is the raw risk intelligence data, if any.
+	RiskIntelligence json.RawMessage `json:"risk_intelligence,omitempty"`
+}
+
+// EventSink receives an Event for every call to `VerifyCaptchaResponse` and `RetrieveRiskIntelligence`.
+//
+// Record is called on the request path, after the call completed: it must not block. The eventsink package contains
+// a file sink that writes events asynchronously.
+type EventSink interface {
+	Record(event Event)
+}
+
+// WithEventSink sets the sink that receives an Event for every call to `VerifyCaptchaResponse` and
+// `RetrieveRiskIntelligence`, e.g. an `eventsink.FileSink` to keep an audit trail for abuse investigations.
+//
+// This defaults to nil, which disables events.
+func WithEventSink(sink EventSink) ClientOption {
+	return func(c *Client) error {
+		c.EventSink = sink
+		return nil
+	}
+}
+
+func (frc *Client) recordVerify(start time.Time, sitekey string, result VerifyResult) {
+	if frc.EventSink == nil {
+		return
+	}
+	event := Event{
+		Time:         start,
+		Operation:    operationVerify,
+		Sitekey:      sitekey,
+		Outcome:      verifyOutcome(result),
+		Status:       result.Status,
+		ErrorCode:    result.ErrorCode(),
+		FailureClass: result.FailureClass(),
+	}
+	if data := result.response.Data; data != nil {
+		event.EventID = data.EventID
+		event.Origin = data.Challenge.Origin
+		if data.RiskIntelligenceRaw.Valid {
+			event.RiskIntelligence = data.RiskIntelligenceRaw.V
+		}
+	}
+	frc.EventSink.Record(event)
+}
+
+func (frc *Client) recordRetrieve(start time.Time, sitekey string, result RiskIntelligenceRetrieveResult) {
+	if frc.EventSink == nil {
+		return
+	}
+	event := Event{
+		Time:         start,
+		Operation:    operationRetrieve,
+		Sitekey:      sitekey,
+		Outcome:      retrieveOutcome(result),
+		Status:       result.Status,
+		ErrorCode:    result.ErrorCode(),
+		FailureClass: result.FailureClass(),
+	}
+	if data := result.response.Data; data != nil {
+		event.EventID = data.EventID
+		event.Origin = data.Token.Origin
+		if data.RiskIntelligenceRaw.Valid {
+			event.RiskIntelligence = data.RiskIntelligenceRaw.V
+		}
+	}
+	frc.EventSink.Record(event)
+}
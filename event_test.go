@@ -0,0 +1,104 @@
+package friendlycaptcha_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/guregu/null/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []friendlycaptcha.Event
+}
+
+func (s *recordingSink) Record(event friendlycaptcha.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *recordingSink) last(t *testing.T) friendlycaptcha.Event {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.NotEmpty(t, s.events)
+	return s.events[len(s.events)-1]
+}
+
+func TestEventSink(t *testing.T) {
+	t.Parallel()
+
+	riskIntelligence := json.RawMessage(`{"risk_scores":{"overall":4,"network":3,"browser":5}}`)
+
+	server := friendlycaptchatest.NewServer()
+	t.Cleanup(server.Close)
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccessWith(friendlycaptcha.VerifyResponseData{
+		EventID:             "ev_verify",
+		Challenge:           friendlycaptcha.VerifyResponseChallengeData{Origin: "https://example.com"},
+		RiskIntelligenceRaw: null.ValueFrom(riskIntelligence),
+	}))
+	server.OnVerify("invalid", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeResponseInvalid))
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+	server.OnRetrieve("token", friendlycaptchatest.RetrieveSuccessWith(friendlycaptcha.RiskIntelligenceRetrieveResponseData{
+		EventID:             "ev_retrieve",
+		Token:               friendlycaptcha.RiskIntelligenceTokenData{Origin: "https://example.org"},
+		RiskIntelligenceRaw: null.ValueFrom(riskIntelligence),
+	}))
+
+	sink := &recordingSink{}
+	client := server.Client(friendlycaptcha.WithSitekey("FC_sitekey"), friendlycaptcha.WithEventSink(sink))
+	ctx := context.Background()
+
+	client.VerifyCaptchaResponse(ctx, "valid")
+	event := sink.last(t)
+	assert.False(t, event.Time.IsZero())
+	assert.Equal(t, friendlycaptcha.Event{
+		Time:             event.Time,
+		Operation:        "siteverify",
+		Sitekey:          "FC_sitekey",
+		Outcome:          "accepted",
+		Status:           200,
+		EventID:          "ev_verify",
+		Origin:           "https://example.com",
+		RiskIntelligence: riskIntelligence,
+	}, event)
+
+	client.VerifyCaptchaResponse(ctx, "invalid")
+	event = sink.last(t)
+	assert.Equal(t, "rejected", event.Outcome)
+	assert.Equal(t, friendlycaptcha.ErrorCodeResponseInvalid, event.ErrorCode)
+	assert.Empty(t, event.RiskIntelligence)
+
+	client.VerifyCaptchaResponse(ctx, "outage")
+	event = sink.last(t)
+	assert.Equal(t, "failed_open", event.Outcome)
+	assert.Equal(t, 503, event.Status)
+	assert.Equal(t, friendlycaptcha.FailureServerError, event.FailureClass)
+
+	client.RetrieveRiskIntelligence(ctx, "token")
+	event = sink.last(t)
+	assert.Equal(t, "risk_intelligence_retrieve", event.Operation)
+	assert.Equal(t, "valid", event.Outcome)
+	assert.Equal(t, "ev_retrieve", event.EventID)
+	assert.Equal(t, "https://example.org", event.Origin)
+	assert.JSONEq(t, string(riskIntelligence), string(event.RiskIntelligence))
+
+	// Events have the same top-level fields as API responses, so they can be replayed.
+	encoded, err := json.Marshal(event)
+	require.NoError(t, err)
+	var decoded struct {
+		EventID          string                               `json:"event_id"`
+		RiskIntelligence friendlycaptcha.RiskIntelligenceData `json:"risk_intelligence"`
+	}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, "ev_retrieve", decoded.EventID)
+	assert.Equal(t, 4, int(decoded.RiskIntelligence.RiskScores.V.Overall))
+}
@@ -0,0 +1,12 @@
+package eventsink
+
+import "time"
+
+// SetNow replaces the clock of the sink, e.g. to get predictable names for rotated files. It must be called before the
+// first event is recorded.
+func SetNow(s *FileSink, now func() time.Time) {
+	s.now = now
+}
+
+// RotatedTimeFormat is the format of the time in the names of rotated files.
+const RotatedTimeFormat = rotatedTimeFormat
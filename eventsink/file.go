@@ -0,0 +1,424 @@
+// Package eventsink contains an EventSink that archives the events of a friendlycaptcha.Client to JSONL files, as an
+// audit trail of captcha decisions for abuse investigations.
+//
+// The sink writes asynchronously, so recording an event never blocks the request path:
+//
+//	sink, err := eventsink.OpenFile("/var/log/frc/events.jsonl",
+//		eventsink.WithMaxSize(100<<20),
+//		eventsink.WithMaxAge(24*time.Hour),
+//		eventsink.WithCompression(),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer sink.Close()
+//
+//	client, err := friendlycaptcha.NewClient(
+//		friendlycaptcha.WithAPIKey(apiKey),
+//		friendlycaptcha.WithEventSink(sink),
+//	)
+//
+// The archived files can be replayed against a policy with the frc-backtest command.
+package eventsink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+)
+
+// DefaultBufferSize is the default number of events that are buffered before events are dropped.
+const DefaultBufferSize = 4096
+
+// rotatedTimeFormat is the format of the time in the names of rotated files, it sorts chronologically.
+const rotatedTimeFormat = "20060102T150405.000000000Z"
+
+// ErrClosed is reported by Flush once the sink is closed.
+var ErrClosed = errors.New("eventsink: sink is closed")
+
+// A FileOption is a function that can be passed to OpenFile to configure a FileSink.
+type FileOption func(*FileSink) error
+
+// WithMaxSize rotates the file once it reaches the given size in bytes.
+//
+// This defaults to 0, which disables rotation by size.
+func WithMaxSize(bytes int64) FileOption {
+	return func(s *FileSink) error {
+		if bytes < 0 {
+			return fmt.Errorf("max size must not be negative")
+		}
+		s.maxSize = bytes
+		return nil
+	}
+}
+
+// WithMaxAge rotates the file once it was opened for the given duration, if events were written to it.
+//
+// This defaults to 0, which disables rotation by age.
+func WithMaxAge(age time.Duration) FileOption {
+	return func(s *FileSink) error {
+		if age < 0 {
+			return fmt.Errorf("max age must not be negative")
+		}
+		s.maxAge = age
+		return nil
+	}
+}
+
+// WithCompression gzips rotated files in the background, adding a ".gz" suffix to their name.
+func WithCompression() FileOption {
+	return func(s *FileSink) error {
+		s.compress = true
+		return nil
+	}
+}
+
+// WithBufferSize sets the number of events that are buffered while they are written. Once the buffer is full, events
+// are dropped instead of blocking the request path, see Dropped.
+//
+// This defaults to DefaultBufferSize.
+func WithBufferSize(size int) FileOption {
+	return func(s *FileSink) error {
+		if size <= 0 {
+			return fmt.Errorf("buffer size must be positive")
+		}
+		s.bufferSize = size
+		return nil
+	}
+}
+
+// WithErrorHook sets a function that is called with every error writing, rotating or compressing a file, e.g. to log
+// it. It is called from the goroutine that writes the events, or from the goroutine that compresses a rotated file, so
+// it must not block. Calls never overlap, the hook doesn't need to be safe for concurrent use.
+//
+// By default errors are ignored, the events that could not be written are lost.
+func WithErrorHook(hook func(err error)) FileOption {
+	return func(s *FileSink) error {
+		s.onError = hook
+		return nil
+	}
+}
+
+// FileSink is a friendlycaptcha.EventSink that appends every event as a JSON line to a file. It rotates the file by
+// size and age: the current file is renamed to "<name>-<time><ext>", e.g.
+// "events-20240102T150405.000000000Z.jsonl", and a new file is opened under the original name.
+//
+// Events are encoded and written by a background goroutine, and flushed to the file as soon as no more events are
+// waiting. Call Close to write the remaining events before the program exits.
+//
+// A FileSink is safe for concurrent use.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	compress   bool
+	bufferSize int
+	onError    func(err error)
+	now        func() time.Time
+
+	// onErrorMu serializes calls to onError, which are also made while compressing.
+	onErrorMu sync.Mutex
+
+	// mu guards closed, events is only sent to while the read lock is held and closed is false.
+	mu      sync.RWMutex
+	closed  bool
+	events  chan friendlycaptcha.Event
+	flushes chan chan struct{}
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// The fields below are only accessed by the goroutine that writes the events.
+	file        *os.File
+	writer      *bufio.Writer
+	size        int64
+	openedAt    time.Time
+	compressing sync.WaitGroup
+}
+
+var _ friendlycaptcha.EventSink = (*FileSink)(nil)
+
+// OpenFile opens the file at the given path for appending events, creating it if it doesn't exist, and starts writing
+// events in the background.
+func OpenFile(path string, opts ...FileOption) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		bufferSize: DefaultBufferSize,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	s.events = make(chan friendlycaptcha.Event, s.bufferSize)
+	s.flushes = make(chan chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// Record implements friendlycaptcha.EventSink. It never blocks: if the buffer is full or the sink is closed, the event
+// is dropped.
+func (s *FileSink) Record(event friendlycaptcha.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events that were dropped because the buffer was full or the sink was closed.
+func (s *FileSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Flush waits until the events recorded before the call are written to the file. It returns ErrClosed if the sink is
+// closed.
+func (s *FileSink) Flush() error {
+	flushed := make(chan struct{})
+	select {
+	case s.flushes <- flushed:
+		<-flushed
+		return nil
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// Close writes the remaining events, closes the file and waits for rotated files to be compressed. Events recorded
+// after Close are dropped.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *FileSink) run() {
+	defer close(s.done)
+	defer s.compressing.Wait()
+	defer s.closeFile()
+
+	var ageTimer *time.Timer
+	var rotateByAge <-chan time.Time
+	if s.maxAge > 0 {
+		ageTimer = time.NewTimer(s.maxAge)
+		defer ageTimer.Stop()
+		rotateByAge = ageTimer.C
+	}
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return
+			}
+			s.write(event)
+			if len(s.events) == 0 {
+				s.flush()
+			}
+		case flushed := <-s.flushes:
+			// Write the events that were recorded before the flush was requested.
+			for n := len(s.events); n > 0; n-- {
+				s.write(<-s.events)
+			}
+			s.flush()
+			close(flushed)
+		case <-rotateByAge:
+			switch {
+			case s.size > 0 && s.now().Sub(s.openedAt) >= s.maxAge:
+				s.rotate()
+			case s.size == 0:
+				// Nothing to rotate, an empty file starts a new period.
+				s.openedAt = s.now()
+			}
+			ageTimer.Reset(s.maxAge - s.now().Sub(s.openedAt))
+			continue
+		}
+
+		if s.maxAge > 0 && s.size > 0 && s.now().Sub(s.openedAt) >= s.maxAge {
+			s.rotate()
+		}
+	}
+}
+
+func (s *FileSink) write(event friendlycaptcha.Event) {
+	if s.writer == nil {
+		// Opening a new file failed while rotating, try again.
+		if err := s.open(); err != nil {
+			s.reportError(err)
+			return
+		}
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		s.reportError(fmt.Errorf("eventsink: encoding event: %w", err))
+		return
+	}
+	line = append(line, '\n')
+	n, err := s.writer.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		s.reportError(fmt.Errorf("eventsink: writing %s: %w", s.path, err))
+		return
+	}
+
+	if s.maxSize > 0 && s.size >= s.maxSize {
+		s.rotate()
+	}
+}
+
+func (s *FileSink) flush() {
+	if s.writer == nil {
+		return
+	}
+	if err := s.writer.Flush(); err != nil {
+		s.reportError(fmt.Errorf("eventsink: writing %s: %w", s.path, err))
+	}
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("eventsink: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("eventsink: %w", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.size = info.Size()
+	s.openedAt = s.now()
+	return nil
+}
+
+func (s *FileSink) closeFile() {
+	if s.file == nil {
+		return
+	}
+	s.flush()
+	if err := s.file.Close(); err != nil {
+		s.reportError(fmt.Errorf("eventsink: closing %s: %w", s.path, err))
+	}
+	s.file, s.writer, s.size = nil, nil, 0
+}
+
+// rotate renames the current file and opens a new one.
+func (s *FileSink) rotate() {
+	s.closeFile()
+
+	rotated := s.rotatedPath()
+	if err := os.Rename(s.path, rotated); err != nil {
+		s.reportError(fmt.Errorf("eventsink: rotating %s: %w", s.path, err))
+	} else if s.compress {
+		s.compressing.Add(1)
+		go func() {
+			defer s.compressing.Done()
+			if err := compressFile(rotated); err != nil {
+				s.reportError(err)
+			}
+		}()
+	}
+
+	if err := s.open(); err != nil {
+		s.reportError(err)
+	}
+}
+
+// rotatedPath returns a path for the current file that is not in use yet.
+func (s *FileSink) rotatedPath() string {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext) + "-" + s.now().UTC().Format(rotatedTimeFormat)
+	for i := 0; ; i++ {
+		path := base + ext
+		if i > 0 {
+			path = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		if !exists(path) && !exists(path+".gz") {
+			return path
+		}
+	}
+}
+
+func (s *FileSink) reportError(err error) {
+	if s.onError != nil {
+		s.onErrorMu.Lock()
+		defer s.onErrorMu.Unlock()
+		s.onError(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// compressFile gzips the file at path to path.gz and removes the original.
+func compressFile(path string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("eventsink: compressing %s: %w", path, err)
+		}
+	}()
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,282 @@
+package eventsink_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/eventsink"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent(eventID string) friendlycaptcha.Event {
+	return friendlycaptcha.Event{
+		Time:             time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Operation:        "siteverify",
+		Sitekey:          "FC_sitekey",
+		Outcome:          "accepted",
+		Status:           200,
+		EventID:          eventID,
+		Origin:           "https://example.com",
+		RiskIntelligence: json.RawMessage(`{"risk_scores":{"overall":2,"network":1,"browser":3}}`),
+	}
+}
+
+// readEvents returns the event IDs in the file, which is decompressed if its name ends in .gz.
+func readEvents(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer gz.Close()
+		r = gz
+	}
+
+	var eventIDs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event friendlycaptcha.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		eventIDs = append(eventIDs, event.EventID)
+	}
+	require.NoError(t, scanner.Err())
+	return eventIDs
+}
+
+// rotatedFiles returns the rotated files in the directory in chronological order.
+func rotatedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var files []string
+	for _, entry := range entries {
+		if entry.Name() != "events.jsonl" {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"event_id":"ev_existing"}`+"\n"), 0o600))
+
+	sink, err := eventsink.OpenFile(path)
+	require.NoError(t, err)
+
+	sink.Record(testEvent("ev_1"))
+	sink.Record(testEvent("ev_2"))
+	require.NoError(t, sink.Flush())
+	assert.Equal(t, []string{"ev_existing", "ev_1", "ev_2"}, readEvents(t, path))
+
+	sink.Record(testEvent("ev_3"))
+	require.NoError(t, sink.Close())
+	require.NoError(t, sink.Close())
+	assert.Equal(t, []string{"ev_existing", "ev_1", "ev_2", "ev_3"}, readEvents(t, path))
+
+	// Events recorded after Close are dropped.
+	sink.Record(testEvent("ev_4"))
+	assert.Equal(t, uint64(1), sink.Dropped())
+	assert.ErrorIs(t, sink.Flush(), eventsink.ErrClosed)
+
+	line, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(line)), "\n")
+	assert.JSONEq(t, `{
+		"time": "2024-01-02T15:04:05Z",
+		"operation": "siteverify",
+		"sitekey": "FC_sitekey",
+		"outcome": "accepted",
+		"status": 200,
+		"event_id": "ev_1",
+		"origin": "https://example.com",
+		"risk_intelligence": {"risk_scores": {"overall": 2, "network": 1, "browser": 3}}
+	}`, lines[1])
+}
+
+func TestFileSinkRotateBySize(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	line, err := json.Marshal(testEvent("ev_0"))
+	require.NoError(t, err)
+
+	// Every file holds two events.
+	sink, err := eventsink.OpenFile(path, eventsink.WithMaxSize(int64(2*(len(line)+1))))
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		sink.Record(testEvent(fmt.Sprintf("ev_%d", i)))
+	}
+	require.NoError(t, sink.Close())
+
+	rotated := rotatedFiles(t, dir)
+	require.Len(t, rotated, 2)
+	for _, name := range rotated {
+		assert.Regexp(t, `events-\d{8}T\d{6}\.\d{9}Z(-\d+)?\.jsonl$`, name)
+	}
+	assert.Equal(t, []string{"ev_0", "ev_1"}, readEvents(t, rotated[0]))
+	assert.Equal(t, []string{"ev_2", "ev_3"}, readEvents(t, rotated[1]))
+	assert.Equal(t, []string{"ev_4"}, readEvents(t, path))
+}
+
+func TestFileSinkRotateByAge(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := eventsink.OpenFile(path, eventsink.WithMaxAge(50*time.Millisecond), eventsink.WithCompression())
+	require.NoError(t, err)
+
+	sink.Record(testEvent("ev_1"))
+	require.NoError(t, sink.Flush())
+
+	// The file is rotated and compressed without further events.
+	require.Eventually(t, func() bool {
+		rotated := rotatedFiles(t, dir)
+		return len(rotated) == 1 && strings.HasSuffix(rotated[0], ".jsonl.gz")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sink.Record(testEvent("ev_2"))
+	require.NoError(t, sink.Close())
+
+	rotated := rotatedFiles(t, dir)
+	require.Len(t, rotated, 1)
+	assert.Equal(t, []string{"ev_1"}, readEvents(t, rotated[0]))
+	assert.Equal(t, []string{"ev_2"}, readEvents(t, path))
+}
+
+func TestFileSinkCompression(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := eventsink.OpenFile(path, eventsink.WithMaxSize(1), eventsink.WithCompression())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		sink.Record(testEvent(fmt.Sprintf("ev_%d", i)))
+	}
+	// Close waits for the rotated files to be compressed.
+	require.NoError(t, sink.Close())
+
+	rotated := rotatedFiles(t, dir)
+	require.Len(t, rotated, 3)
+	var eventIDs []string
+	for _, name := range rotated {
+		assert.True(t, strings.HasSuffix(name, ".jsonl.gz"), name)
+		eventIDs = append(eventIDs, readEvents(t, name)...)
+	}
+	assert.Equal(t, []string{"ev_0", "ev_1", "ev_2"}, eventIDs)
+	assert.Empty(t, readEvents(t, path))
+}
+
+func TestFileSinkErrorHookIsSerialized(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	// The hook is not synchronized, the race detector reports concurrent calls.
+	var errs []error
+	sink, err := eventsink.OpenFile(path,
+		eventsink.WithMaxSize(1),
+		eventsink.WithCompression(),
+		eventsink.WithErrorHook(func(err error) { errs = append(errs, err) }),
+	)
+	require.NoError(t, err)
+
+	// With a fixed clock the rotated files get predictable names. Compressing them fails if their temporary file is
+	// taken by a directory.
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	eventsink.SetNow(sink, func() time.Time { return now })
+	const rotations = 20
+	base := filepath.Join(dir, "events-"+now.Format(eventsink.RotatedTimeFormat))
+	for i := 0; i < rotations; i++ {
+		rotated := base + ".jsonl"
+		if i > 0 {
+			rotated = fmt.Sprintf("%s-%d.jsonl", base, i)
+		}
+		require.NoError(t, os.Mkdir(rotated+".gz.tmp", 0o750))
+	}
+
+	// Every event is written and rotated, and compressing the rotated file fails while the next event that can not be
+	// encoded fails on the writer.
+	for i := 0; i < rotations; i++ {
+		sink.Record(testEvent(fmt.Sprintf("ev_%d", i)))
+		sink.Record(friendlycaptcha.Event{RiskIntelligence: json.RawMessage(`{`)})
+	}
+	require.NoError(t, sink.Close())
+
+	var encoding, compressing int
+	for _, err := range errs {
+		switch {
+		case strings.Contains(err.Error(), "encoding event"):
+			encoding++
+		case strings.Contains(err.Error(), "compressing"):
+			compressing++
+		}
+	}
+	assert.Equal(t, rotations, encoding)
+	assert.Equal(t, rotations, compressing)
+	assert.Len(t, errs, 2*rotations)
+}
+
+func TestFileSinkDropsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	// The writer blocks in the error hook, so events pile up in the buffer.
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	blocked := make(chan struct{})
+	var once sync.Once
+	entered := make(chan struct{})
+	sink, err := eventsink.OpenFile(path,
+		eventsink.WithBufferSize(2),
+		eventsink.WithErrorHook(func(err error) {
+			once.Do(func() { close(entered) })
+			<-blocked
+		}),
+	)
+	require.NoError(t, err)
+
+	// An event that can not be encoded is reported to the error hook.
+	sink.Record(friendlycaptcha.Event{RiskIntelligence: json.RawMessage(`{`)})
+	<-entered
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		sink.Record(testEvent(fmt.Sprintf("ev_%d", i)))
+	}
+	assert.Less(t, time.Since(start), time.Second, "Record must not block")
+	assert.Equal(t, uint64(3), sink.Dropped())
+
+	close(blocked)
+	require.NoError(t, sink.Close())
+}
+
+func TestOpenFileErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := eventsink.OpenFile(filepath.Join(t.TempDir(), "missing", "events.jsonl"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = eventsink.OpenFile(filepath.Join(t.TempDir(), "events.jsonl"), eventsink.WithBufferSize(0))
+	assert.ErrorContains(t, err, "buffer size must be positive")
+}
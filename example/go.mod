@@ -8,4 +8,11 @@ require github.com/friendlycaptcha/friendly-captcha-go v0.0.0
 // the `github.com/friendlycaptcha/friendly-captcha-go` dependency above to the latest version.
 replace github.com/friendlycaptcha/friendly-captcha-go => ../
 
-require github.com/guregu/null/v6 v6.0.0 // indirect
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/guregu/null/v6 v6.0.0 // indirect
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+)
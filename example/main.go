@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -38,6 +39,8 @@ func main() {
 	opts := []friendlycaptcha.ClientOption{
 		friendlycaptcha.WithAPIKey(apikey),
 		friendlycaptcha.WithSitekey(sitekey),
+		// Log every verification and retrieval, failures to verify are logged at warn or error level.
+		friendlycaptcha.WithLogger(slog.Default()),
 	}
 	if apiEndpoint != "" {
 		opts = append(opts, friendlycaptcha.WithAPIEndpoint(apiEndpoint))
@@ -68,19 +71,12 @@ func main() {
 		solution := r.FormValue("frc-captcha-response")
 		result := frcClient.VerifyCaptchaResponse(r.Context(), solution)
 
-		if !result.WasAbleToVerify() {
-			// In this case we were not actually able to verify the response embedded in the form, but we may still want to accept it.
-			// It could mean there is a network issue or that the service is down. In those cases you generally want to accept submissions anyhow.
-			// That's why we use `ShouldAccept()` below to actually accept or reject the form submission. It will return true in these cases.
-
-			if result.IsErrorDueToClientError() {
-				// Something is wrong with our configuration, check your API key!
-				// Send yourself an alert to fix this! Your site is unprotected until you fix this.
-				log.Printf("CAPTCHA CONFIG ERROR: %s\n", result.RequestError())
-			} else {
-				log.Printf("Failed to verify captcha response: %s\n", result.RequestError())
-			}
-		}
+		// If we were not able to verify the response embedded in the form, we may still want to accept it.
+		// It could mean there is a network issue or that the service is down. In those cases you generally want to accept submissions anyhow.
+		// That's why we use `ShouldAccept()` below to actually accept or reject the form submission. It will return true in these cases.
+		//
+		// The reason is logged by the client (see `WithLogger`). Errors due to our configuration, such as an invalid API key,
+		// are logged at error level: send yourself an alert for those! Your site is unprotected until you fix this.
 
 		if !result.ShouldAccept() {
 			renderTemplate(w, tmpl, templateData{
@@ -0,0 +1,210 @@
+package friendlycaptcha
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// FailureClass classifies why a request to the Friendly Captcha API failed.
+type FailureClass string
+
+const (
+	// FailureNone means that the request did not fail.
+	FailureNone FailureClass = ""
+	// FailureNetworkError means that the API could not be reached, e.g. because the connection was refused or dropped.
+	FailureNetworkError FailureClass = "network_error"
+	// FailureTimeout means that the API did not respond in time, e.g. because the `http.Client.Timeout` was exceeded.
+	FailureTimeout FailureClass = "timeout"
+	// FailureServerError means that the API responded with a 5xx status code.
+	FailureServerError FailureClass = "server_error"
+	// FailureRateLimited means that the API responded with a 429 status code.
+	FailureRateLimited FailureClass = "rate_limited"
+	// FailureClientError means that the API responded with a 4xx status code, the ErrorCode in the response
+	// tells why (e.g. `auth_invalid`).
+	FailureClientError FailureClass = "client_error"
+	// FailureUndecodableBody means that the response body of the API could not be decoded.
+	FailureUndecodableBody FailureClass = "undecodable_body"
+	// FailureCircuitOpen means that no request was sent because the circuit breaker is open.
+	FailureCircuitOpen FailureClass = "circuit_open"
+	// FailureOverloaded means that no request was sent because too many calls were in flight, see WithMaxInFlight.
+	FailureOverloaded FailureClass = "overloaded"
+	// FailureContextCanceled means that the context passed to the client was canceled. Such results are always
+	// rejected, see ErrContextCanceled.
+	FailureContextCanceled FailureClass = "context_canceled"
+	// FailureReplayed means that the captcha response was not sent to the API because it was already submitted
+	// before, see WithReplayProtection. Such results are always rejected.
+	FailureReplayed FailureClass = "replayed"
+	// FailureCreatingRequest means that the request could not be created. Such results are always rejected.
+	FailureCreatingRequest FailureClass = "creating_request"
+	// FailureUnknown is used for errors that can not be classified. Such results are always rejected.
+	FailureUnknown FailureClass = "unknown"
+)
+
+// FailureAction is what to do with a captcha response that could not be verified.
+type FailureAction int
+
+const (
+	// FailClosed rejects the captcha response.
+	FailClosed FailureAction = iota
+	// FailOpen accepts the captcha response.
+	FailOpen
+)
+
+// FailurePolicy decides per FailureClass whether a captcha response that could not be verified should be accepted
+// (FailOpen) or rejected (FailClosed). This allows you to e.g. accept responses while the API is down, but reject them
+// when your API key was revoked.
+//
+// Results with FailureContextCanceled, FailureReplayed, FailureCreatingRequest or FailureUnknown are always rejected.
+//
+// The zero value rejects everything.
+type FailurePolicy struct {
+	NetworkError    FailureAction
+	Timeout         FailureAction
+	ServerError     FailureAction
+	RateLimited     FailureAction
+	UndecodableBody FailureAction
+	CircuitOpen     FailureAction
+	Overloaded      FailureAction
+
+	// ClientErrors contains the action per ErrorCode for 4xx responses, e.g. `ErrorCodeAuthInvalid`.
+	ClientErrors map[ErrorCode]FailureAction
+	// DefaultClientError is the action for 4xx responses with an ErrorCode that is not in ClientErrors.
+	DefaultClientError FailureAction
+}
+
+// FailOpenPolicy returns a FailurePolicy that accepts captcha responses for every class of failure. This is the
+// default, and what `WithStrictMode(false)` configures.
+func FailOpenPolicy() FailurePolicy {
+	return FailurePolicy{
+		NetworkError:       FailOpen,
+		Timeout:            FailOpen,
+		ServerError:        FailOpen,
+		RateLimited:        FailOpen,
+		UndecodableBody:    FailOpen,
+		CircuitOpen:        FailOpen,
+		Overloaded:         FailOpen,
+		DefaultClientError: FailOpen,
+	}
+}
+
+// FailClosedPolicy returns a FailurePolicy that rejects captcha responses for every class of failure. This is what
+// `WithStrictMode(true)` configures.
+func FailClosedPolicy() FailurePolicy {
+	return FailurePolicy{}
+}
+
+// WithFailurePolicy sets the policy that decides whether captcha responses that could not be verified are accepted.
+// It overrides the policy set by WithStrictMode, or is overridden by it if WithStrictMode comes later.
+//
+// By default all failures are accepted, see FailOpenPolicy.
+func WithFailurePolicy(policy FailurePolicy) ClientOption {
+	return func(c *Client) error {
+		c.FailurePolicy = &policy
+		return nil
+	}
+}
+
+// Action returns the action for the given class of failure. The error code is only used for FailureClientError.
+func (p FailurePolicy) Action(class FailureClass, code ErrorCode) FailureAction {
+	switch class {
+	case FailureNetworkError:
+		return p.NetworkError
+	case FailureTimeout:
+		return p.Timeout
+	case FailureServerError:
+		return p.ServerError
+	case FailureRateLimited:
+		return p.RateLimited
+	case FailureUndecodableBody:
+		return p.UndecodableBody
+	case FailureCircuitOpen:
+		return p.CircuitOpen
+	case FailureOverloaded:
+		return p.Overloaded
+	case FailureClientError:
+		if action, ok := p.ClientErrors[code]; ok {
+			return action
+		}
+		return p.DefaultClientError
+	default:
+		return FailClosed
+	}
+}
+
+// strictFailurePolicy returns the preset policy for the given strict mode.
+func strictFailurePolicy(strict bool) FailurePolicy {
+	if strict {
+		return FailClosedPolicy()
+	}
+	return FailOpenPolicy()
+}
+
+// classifyStatus classifies a response with a non-200 status code.
+func classifyStatus(statusCode int) FailureClass {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return FailureRateLimited
+	case statusCode >= 500:
+		return FailureServerError
+	default:
+		return FailureClientError
+	}
+}
+
+// classifyCall classifies the outcome of a call to the API, it returns FailureNone if the call succeeded with a 200.
+func classifyCall(call apiCall, err error) FailureClass {
+	switch {
+	case err == nil && call.statusCode == http.StatusOK:
+		return FailureNone
+	case errors.Is(err, errCreateRequest):
+		return FailureCreatingRequest
+	case errors.Is(err, ErrCircuitOpen):
+		return FailureCircuitOpen
+	case errors.Is(err, ErrOverloaded):
+		return FailureOverloaded
+	case errors.Is(err, ErrContextCanceled):
+		return FailureContextCanceled
+	case err != nil && call.statusCode == -1:
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return FailureTimeout
+		}
+		return FailureNetworkError
+	case call.statusCode != http.StatusOK:
+		return classifyStatus(call.statusCode)
+	default: // A 200 response that could not be decoded.
+		return FailureUndecodableBody
+	}
+}
+
+// isRejection returns whether the error is the reason the client rejected a captcha response that was verified
+// successfully, which is not a failure to verify it.
+func isRejection(err error) bool {
+	return errors.Is(err, ErrOriginNotAllowed) || errors.Is(err, ErrChallengeTooOld)
+}
+
+// classifyVerifyError classifies the error of a VerifyResult that was not created by the Client, e.g. using
+// NewVerifyResult.
+func classifyVerifyError(status int, err error) FailureClass {
+	switch {
+	case err == nil, isRejection(err):
+		return FailureNone
+	case errors.Is(err, ErrContextCanceled):
+		return FailureContextCanceled
+	case errors.Is(err, ErrResponseReplayed):
+		return FailureReplayed
+	case errors.Is(err, ErrCircuitOpen):
+		return FailureCircuitOpen
+	case errors.Is(err, ErrOverloaded):
+		return FailureOverloaded
+	case errors.Is(err, ErrVerificationRequest):
+		return FailureNetworkError
+	case errors.Is(err, ErrVerificationFailedDueToClientError):
+		return classifyStatus(status)
+	case errors.Is(err, ErrCreatingVerificationRequest):
+		return FailureCreatingRequest
+	default:
+		return FailureUnknown
+	}
+}
@@ -0,0 +1,173 @@
+package friendlycaptcha_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailurePolicyClasses(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+
+	server.OnVerify("network", friendlycaptchatest.DropConnection())
+	server.OnVerify("timeout", friendlycaptchatest.VerifySuccess().WithDelay(5*time.Second))
+	server.OnVerify("5xx", friendlycaptchatest.Status(http.StatusBadGateway, "Bad Gateway"))
+	server.OnVerify("429", friendlycaptchatest.Status(http.StatusTooManyRequests, "{}"))
+	server.OnVerify("malformed", friendlycaptchatest.MalformedJSON())
+	server.OnVerify("sitekey", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeSitekeyInvalid))
+
+	tests := []struct {
+		response string
+		apiKey   string
+		class    friendlycaptcha.FailureClass
+		code     friendlycaptcha.ErrorCode
+	}{
+		{response: "network", class: friendlycaptcha.FailureNetworkError},
+		{response: "timeout", class: friendlycaptcha.FailureTimeout},
+		{response: "5xx", class: friendlycaptcha.FailureServerError},
+		{response: "429", class: friendlycaptcha.FailureRateLimited},
+		{response: "malformed", class: friendlycaptcha.FailureUndecodableBody},
+		{
+			response: "sitekey",
+			class:    friendlycaptcha.FailureClientError,
+			code:     friendlycaptcha.ErrorCodeSitekeyInvalid,
+		},
+		{
+			response: "anything",
+			apiKey:   "revoked",
+			class:    friendlycaptcha.FailureClientError,
+			code:     friendlycaptcha.ErrorCodeAuthInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.response, func(t *testing.T) {
+			opts := []friendlycaptcha.ClientOption{}
+			if tt.apiKey != "" {
+				opts = append(opts, friendlycaptcha.WithAPIKey(tt.apiKey))
+			}
+
+			// A policy that only accepts this exact class of failure.
+			policy := friendlycaptcha.FailClosedPolicy()
+			if tt.class == friendlycaptcha.FailureClientError {
+				policy.ClientErrors = map[friendlycaptcha.ErrorCode]friendlycaptcha.FailureAction{
+					tt.code: friendlycaptcha.FailOpen,
+				}
+			} else {
+				setAction(&policy, tt.class, friendlycaptcha.FailOpen)
+			}
+
+			client := server.Client(append(opts, friendlycaptcha.WithFailurePolicy(policy))...)
+			client.HTTPClient = &http.Client{Timeout: 100 * time.Millisecond}
+
+			result := client.VerifyCaptchaResponse(context.Background(), tt.response)
+			assert.False(t, result.WasAbleToVerify())
+			assert.Equal(t, tt.class, result.FailureClass())
+			assert.Equal(t, tt.code, result.ErrorCode())
+			assert.True(t, result.ShouldAccept())
+
+			client.FailurePolicy = &friendlycaptcha.FailurePolicy{}
+			result = client.VerifyCaptchaResponse(context.Background(), tt.response)
+			assert.False(t, result.ShouldAccept())
+		})
+	}
+}
+
+func setAction(policy *friendlycaptcha.FailurePolicy, class friendlycaptcha.FailureClass, action friendlycaptcha.FailureAction) {
+	switch class {
+	case friendlycaptcha.FailureNetworkError:
+		policy.NetworkError = action
+	case friendlycaptcha.FailureTimeout:
+		policy.Timeout = action
+	case friendlycaptcha.FailureServerError:
+		policy.ServerError = action
+	case friendlycaptcha.FailureRateLimited:
+		policy.RateLimited = action
+	case friendlycaptcha.FailureUndecodableBody:
+		policy.UndecodableBody = action
+	case friendlycaptcha.FailureCircuitOpen:
+		policy.CircuitOpen = action
+	default:
+		panic(fmt.Sprintf("unexpected class %q", class))
+	}
+}
+
+func TestFailurePolicyOpenOnOutageClosedOnRevokedKey(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+
+	policy := friendlycaptcha.FailOpenPolicy()
+	policy.ClientErrors = map[friendlycaptcha.ErrorCode]friendlycaptcha.FailureAction{
+		friendlycaptcha.ErrorCodeAuthInvalid: friendlycaptcha.FailClosed,
+	}
+
+	client := server.Client(friendlycaptcha.WithFailurePolicy(policy))
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "outage").ShouldAccept())
+
+	server.SetAPIKey("rotated")
+	result := client.VerifyCaptchaResponse(context.Background(), "outage")
+	assert.True(t, result.IsErrorDueToClientError())
+	assert.False(t, result.ShouldAccept())
+}
+
+func TestStrictModeIsAFailurePolicyPreset(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+
+	client := server.Client(friendlycaptcha.WithFailurePolicy(friendlycaptcha.FailOpenPolicy()), friendlycaptcha.WithStrictMode(true))
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "outage").ShouldAccept())
+
+	client = server.Client(friendlycaptcha.WithStrictMode(true), friendlycaptcha.WithFailurePolicy(friendlycaptcha.FailOpenPolicy()))
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "outage").ShouldAccept())
+
+	// Without any option, the Strict field is used.
+	client = server.Client()
+	client.Strict = true
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "outage").ShouldAccept())
+
+	// Also if it is changed after WithStrictMode.
+	client = server.Client(friendlycaptcha.WithStrictMode(false))
+	client.Strict = true
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "outage").ShouldAccept())
+}
+
+func TestNewVerifyResultFailureClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status int
+		err    error
+		class  friendlycaptcha.FailureClass
+		accept bool
+	}{
+		{status: -1, err: friendlycaptcha.ErrVerificationRequest, class: friendlycaptcha.FailureNetworkError, accept: true},
+		{status: 401, err: friendlycaptcha.ErrVerificationFailedDueToClientError, class: friendlycaptcha.FailureClientError, accept: true},
+		{status: 503, err: friendlycaptcha.ErrVerificationFailedDueToClientError, class: friendlycaptcha.FailureServerError, accept: true},
+		{status: -1, err: friendlycaptcha.ErrCreatingVerificationRequest, class: friendlycaptcha.FailureCreatingRequest},
+		{status: -1, err: fmt.Errorf("something else"), class: friendlycaptcha.FailureUnknown},
+	}
+
+	for _, tt := range tests {
+		result := friendlycaptcha.NewVerifyResult(friendlycaptcha.VerifyResponse{}, tt.status, false, tt.err)
+		assert.Equal(t, tt.class, result.FailureClass())
+		assert.Equal(t, tt.accept, result.ShouldAccept(), tt.class)
+
+		strictResult := friendlycaptcha.NewVerifyResult(friendlycaptcha.VerifyResponse{}, tt.status, true, tt.err)
+		assert.False(t, strictResult.ShouldAccept(), tt.class)
+	}
+}
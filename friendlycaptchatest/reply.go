@@ -0,0 +1,188 @@
+package friendlycaptchatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/guregu/null/v6"
+)
+
+// Reply is a scripted reply of the Server, create one with VerifySuccess, RetrieveSuccess, Error, Status,
+// MalformedJSON or DropConnection.
+type Reply struct {
+	statusCode     int
+	body           func() []byte
+	delay          time.Duration
+	dropConnection bool
+}
+
+// WithDelay returns a copy of the reply that is only sent after the given delay. The delay is cut short if the
+// client gives up on the request.
+func (r Reply) WithDelay(delay time.Duration) Reply {
+	r.delay = delay
+	return r
+}
+
+// VerifySuccess returns a successful siteverify reply. The challenge was solved on "http://localhost" at the time the
+// request is received, no risk intelligence is included.
+func VerifySuccess() Reply {
+	return VerifySuccessWith(friendlycaptcha.VerifyResponseData{
+		EventID: "ev_friendlycaptchatest",
+		Challenge: friendlycaptcha.VerifyResponseChallengeData{
+			Origin: "http://localhost",
+		},
+	})
+}
+
+// VerifySuccessWith returns a successful siteverify reply with the given data. A zero challenge timestamp is replaced
+// with the time the request is received. If RiskIntelligence is set but RiskIntelligenceRaw is not, the raw JSON is
+// generated from it.
+func VerifySuccessWith(data friendlycaptcha.VerifyResponseData) Reply {
+	return jsonReply(http.StatusOK, func() any {
+		data := data
+		if data.Challenge.Timestamp.IsZero() {
+			data.Challenge.Timestamp = time.Now().UTC()
+		}
+		data.RiskIntelligenceRaw = rawRiskIntelligence(data.RiskIntelligenceRaw, data.RiskIntelligence)
+		return friendlycaptcha.VerifyResponse{Success: true, Data: &data}
+	})
+}
+
+// RetrieveSuccess returns a successful risk intelligence retrieve reply with the given risk intelligence, for a token
+// generated on "http://localhost" at the time the request is received.
+func RetrieveSuccess(riskIntelligence friendlycaptcha.RiskIntelligenceData) Reply {
+	return RetrieveSuccessWith(friendlycaptcha.RiskIntelligenceRetrieveResponseData{
+		EventID: "ev_friendlycaptchatest",
+		Token: friendlycaptcha.RiskIntelligenceTokenData{
+			Origin:  "http://localhost",
+			NumUses: 1,
+		},
+		RiskIntelligence: null.ValueFrom(riskIntelligence),
+	})
+}
+
+// RetrieveSuccessWith returns a successful risk intelligence retrieve reply with the given data. Zero token timestamps
+// are replaced with the time the request is received. If RiskIntelligence is set but RiskIntelligenceRaw is not, the
+// raw JSON is generated from it.
+func RetrieveSuccessWith(data friendlycaptcha.RiskIntelligenceRetrieveResponseData) Reply {
+	return jsonReply(http.StatusOK, func() any {
+		data := data
+		if data.Token.Timestamp.IsZero() {
+			data.Token.Timestamp = time.Now().UTC()
+		}
+		if data.Token.ExpiresAt.IsZero() {
+			data.Token.ExpiresAt = data.Token.Timestamp.Add(time.Hour)
+		}
+		data.RiskIntelligenceRaw = rawRiskIntelligence(data.RiskIntelligenceRaw, data.RiskIntelligence)
+		return friendlycaptcha.RiskIntelligenceRetrieveResponse{Success: true, Data: &data}
+	})
+}
+
+// Error returns an error reply with the given error code, with the HTTP status code the real API uses for it.
+func Error(code friendlycaptcha.ErrorCode) Reply {
+	return ErrorWithStatus(errorStatusCode(code), code)
+}
+
+// ErrorWithStatus returns an error reply with the given HTTP status code and error code.
+func ErrorWithStatus(statusCode int, code friendlycaptcha.ErrorCode) Reply {
+	return jsonReply(statusCode, func() any {
+		return friendlycaptcha.VerifyResponse{
+			Success: false,
+			Error: &friendlycaptcha.VerifyResponseError{
+				ErrorCode: code,
+				Detail:    "friendlycaptchatest: " + string(code),
+			},
+		}
+	})
+}
+
+// Status returns a reply with the given HTTP status code and raw body, e.g. to simulate a 503 from a load balancer.
+func Status(statusCode int, body string) Reply {
+	return Reply{
+		statusCode: statusCode,
+		body:       func() []byte { return []byte(body) },
+	}
+}
+
+// MalformedJSON returns a 200 reply with a body that can not be decoded.
+func MalformedJSON() Reply {
+	return Status(http.StatusOK, `{"success":tru`)
+}
+
+// DropConnection returns a reply that closes the connection without sending a response.
+func DropConnection() Reply {
+	return Reply{dropConnection: true}
+}
+
+func jsonReply(statusCode int, value func() any) Reply {
+	return Reply{
+		statusCode: statusCode,
+		body: func() []byte {
+			body, err := json.Marshal(value())
+			if err != nil {
+				panic("friendlycaptchatest: failed to marshal reply: " + err.Error())
+			}
+			return body
+		},
+	}
+}
+
+func rawRiskIntelligence(
+	raw null.Value[json.RawMessage],
+	data null.Value[friendlycaptcha.RiskIntelligenceData],
+) null.Value[json.RawMessage] {
+	if raw.Valid || !data.Valid {
+		return raw
+	}
+	encoded, err := json.Marshal(data.V)
+	if err != nil {
+		panic("friendlycaptchatest: failed to marshal risk intelligence: " + err.Error())
+	}
+	return null.ValueFrom(json.RawMessage(encoded))
+}
+
+func errorStatusCode(code friendlycaptcha.ErrorCode) int {
+	switch code {
+	case friendlycaptcha.ErrorCodeAuthRequired, friendlycaptcha.ErrorCodeAuthInvalid:
+		return http.StatusUnauthorized
+	case friendlycaptcha.ErrorCodeSitekeyInvalid,
+		friendlycaptcha.ErrorCodeResponseMissing,
+		friendlycaptcha.ErrorCodeTokenMissing,
+		friendlycaptcha.ErrorCodeBadRequest:
+		return http.StatusBadRequest
+	default:
+		return http.StatusOK
+	}
+}
+
+func (r Reply) serve(w http.ResponseWriter, req *http.Request) {
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return
+		case <-timer.C:
+		}
+	}
+
+	if r.dropConnection {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+
+	statusCode := r.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if r.body != nil {
+		_, _ = w.Write(r.body())
+	}
+}
@@ -0,0 +1,223 @@
+// Package friendlycaptchatest provides utilities for testing code that uses the Friendly Captcha SDK without talking
+// to the real Friendly Captcha API.
+//
+// The Server type is an in-process fake of the Friendly Captcha API, for which responses can be scripted per captcha
+// response or risk intelligence token:
+//
+//	server := friendlycaptchatest.NewServer()
+//	defer server.Close()
+//
+//	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+//	server.OnVerify("reused", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeResponseDuplicate))
+//	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+//
+//	frcClient := server.Client(friendlycaptcha.WithSitekey("YOUR_SITEKEY"))
+//
+// The stubs AcceptAll, RejectAll, FailOpen and Scripted implement the friendlycaptcha.Verifier and
+// friendlycaptcha.RiskIntelligenceRetriever interfaces without a server. They ignore CallOptions.
+package friendlycaptchatest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+)
+
+// APIKey is the API key the Server accepts by default, it is configured on clients created by Server.Client.
+const APIKey = "friendlycaptchatest-api-key"
+
+const (
+	siteverifyPath = "/api/v2/captcha/siteverify"
+	retrievePath   = "/api/v2/riskIntelligence/retrieve"
+)
+
+// Request is a request that was received by the Server.
+type Request struct {
+	// Path is the URL path of the request, e.g. "/api/v2/captcha/siteverify".
+	Path string
+	// Header contains the request headers.
+	Header http.Header
+	// Body is the raw request body.
+	Body []byte
+	// Sitekey is the sitekey sent with the request, if any.
+	Sitekey string
+	// Response is the captcha response for siteverify requests.
+	Response string
+	// Token is the risk intelligence token for retrieve requests.
+	Token string
+}
+
+// Server is a fake Friendly Captcha API running on a local httptest.Server. It implements the siteverify and the
+// risk intelligence retrieve endpoints.
+//
+// Unless scripted otherwise, siteverify requests are answered with a `response_invalid` error and retrieve requests
+// with a `token_invalid` error. Requests without the right API key are answered like the real API would.
+type Server struct {
+	server *httptest.Server
+
+	mu              sync.Mutex
+	apiKey          string
+	verifyReplies   map[string][]Reply
+	retrieveReplies map[string][]Reply
+	defaultVerify   Reply
+	defaultRetrieve Reply
+	requests        []Request
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		apiKey:          APIKey,
+		verifyReplies:   make(map[string][]Reply),
+		retrieveReplies: make(map[string][]Reply),
+		defaultVerify:   Error(friendlycaptcha.ErrorCodeResponseInvalid),
+		defaultRetrieve: Error(friendlycaptcha.ErrorCodeTokenInvalid),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base URL of the server, to be used as API endpoint.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts down the server and blocks until all outstanding requests on this server have completed.
+func (s *Server) Close() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+// Client returns a new Client that talks to this server, with the given options applied on top.
+// It panics if the options are invalid.
+func (s *Server) Client(opts ...friendlycaptcha.ClientOption) *friendlycaptcha.Client {
+	s.mu.Lock()
+	apiKey := s.apiKey
+	s.mu.Unlock()
+
+	opts = append([]friendlycaptcha.ClientOption{
+		friendlycaptcha.WithAPIKey(apiKey),
+		friendlycaptcha.WithAPIEndpoint(s.URL()),
+	}, opts...)
+
+	client, err := friendlycaptcha.NewClient(opts...)
+	if err != nil {
+		panic("friendlycaptchatest: failed to create client: " + err.Error())
+	}
+	return client
+}
+
+// SetAPIKey sets the API key the server accepts.
+func (s *Server) SetAPIKey(apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = apiKey
+}
+
+// OnVerify scripts the replies for siteverify requests with the given captcha response. If multiple replies are
+// given they are used in order for subsequent requests, the last reply is repeated once all others are used.
+func (s *Server) OnVerify(response string, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifyReplies[response] = replies
+}
+
+// OnRetrieve scripts the replies for retrieve requests with the given risk intelligence token. If multiple replies
+// are given they are used in order for subsequent requests, the last reply is repeated once all others are used.
+func (s *Server) OnRetrieve(token string, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retrieveReplies[token] = replies
+}
+
+// SetDefaultVerifyReply sets the reply for siteverify requests with a captcha response that was not scripted
+// with OnVerify.
+func (s *Server) SetDefaultVerifyReply(reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultVerify = reply
+}
+
+// SetDefaultRetrieveReply sets the reply for retrieve requests with a token that was not scripted with OnRetrieve.
+func (s *Server) SetDefaultRetrieveReply(reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultRetrieve = reply
+}
+
+// Requests returns all requests the server received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || (r.URL.Path != siteverifyPath && r.URL.Path != retrievePath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	request := Request{
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+	}
+	var body struct {
+		Response string `json:"response"`
+		Token    string `json:"token"`
+		Sitekey  string `json:"sitekey"`
+	}
+	rawBody, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(rawBody, &body)
+	}
+	request.Body = rawBody
+	request.Sitekey = body.Sitekey
+	request.Response = body.Response
+	request.Token = body.Token
+
+	reply := s.nextReply(request, err)
+	reply.serve(w, r)
+}
+
+// nextReply records the request and returns the reply for it.
+func (s *Server) nextReply(request Request, decodeErr error) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+
+	switch apiKey := request.Header.Get("X-Api-Key"); {
+	case apiKey == "":
+		return Error(friendlycaptcha.ErrorCodeAuthRequired)
+	case apiKey != s.apiKey:
+		return Error(friendlycaptcha.ErrorCodeAuthInvalid)
+	case decodeErr != nil:
+		return Error(friendlycaptcha.ErrorCodeBadRequest)
+	}
+
+	if request.Path == siteverifyPath {
+		if request.Response == "" {
+			return Error(friendlycaptcha.ErrorCodeResponseMissing)
+		}
+		return popReply(s.verifyReplies, request.Response, s.defaultVerify)
+	}
+	if request.Token == "" {
+		return Error(friendlycaptcha.ErrorCodeTokenMissing)
+	}
+	return popReply(s.retrieveReplies, request.Token, s.defaultRetrieve)
+}
+
+func popReply(replies map[string][]Reply, key string, defaultReply Reply) Reply {
+	queue, ok := replies[key]
+	if !ok || len(queue) == 0 {
+		return defaultReply
+	}
+	if len(queue) > 1 {
+		replies[key] = queue[1:]
+	}
+	return queue[0]
+}
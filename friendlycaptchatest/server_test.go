@@ -0,0 +1,148 @@
+package friendlycaptchatest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/guregu/null/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerVerify(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+	server.OnVerify("duplicate", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeResponseDuplicate))
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "<html>oops</html>"))
+	server.OnVerify("malformed", friendlycaptchatest.MalformedJSON())
+	server.OnVerify("dropped", friendlycaptchatest.DropConnection())
+	server.OnVerify("bad-sitekey", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeSitekeyInvalid))
+
+	tests := []struct {
+		response        string
+		strict          bool
+		wasAbleToVerify bool
+		shouldAccept    bool
+		isClientError   bool
+		status          int
+	}{
+		{response: "valid", wasAbleToVerify: true, shouldAccept: true, status: 200},
+		{response: "unscripted", wasAbleToVerify: true, shouldAccept: false, status: 200},
+		{response: "duplicate", wasAbleToVerify: true, shouldAccept: false, status: 200},
+		{response: "outage", shouldAccept: true, status: 503},
+		{response: "outage", strict: true, shouldAccept: false, status: 503},
+		{response: "malformed", shouldAccept: true, status: 200},
+		{response: "dropped", shouldAccept: true, status: -1},
+		{response: "dropped", strict: true, shouldAccept: false, status: -1},
+		{response: "bad-sitekey", shouldAccept: true, isClientError: true, status: 400},
+		{response: "bad-sitekey", strict: true, shouldAccept: false, isClientError: true, status: 400},
+	}
+
+	for _, tt := range tests {
+		client := server.Client(friendlycaptcha.WithStrictMode(tt.strict))
+		result := client.VerifyCaptchaResponse(context.Background(), tt.response)
+
+		assert.Equal(t, tt.wasAbleToVerify, result.WasAbleToVerify(), tt.response)
+		assert.Equal(t, tt.shouldAccept, result.ShouldAccept(), tt.response)
+		assert.Equal(t, tt.isClientError, result.IsErrorDueToClientError(), tt.response)
+		assert.Equal(t, tt.status, result.Status, tt.response)
+	}
+}
+
+func TestServerVerifyScriptedSequence(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+
+	server.OnVerify("response",
+		friendlycaptchatest.VerifySuccess(),
+		friendlycaptchatest.Error(friendlycaptcha.ErrorCodeResponseDuplicate),
+	)
+	client := server.Client(friendlycaptcha.WithSitekey("sitekey"))
+
+	first := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, first.ShouldAccept())
+	assert.Equal(t, "http://localhost", first.Response().Data.Challenge.Origin)
+	assert.WithinDuration(t, time.Now(), first.Response().Data.Challenge.Timestamp, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		result := client.VerifyCaptchaResponse(context.Background(), "response")
+		assert.False(t, result.ShouldAccept())
+		assert.Equal(t, friendlycaptcha.ErrorCodeResponseDuplicate, result.Response().Error.ErrorCode)
+	}
+
+	requests := server.Requests()
+	assert.Len(t, requests, 3)
+	assert.Equal(t, "/api/v2/captcha/siteverify", requests[0].Path)
+	assert.Equal(t, "sitekey", requests[0].Sitekey)
+	assert.Equal(t, "response", requests[0].Response)
+	assert.Equal(t, friendlycaptchatest.APIKey, requests[0].Header.Get("X-Api-Key"))
+}
+
+func TestServerAuthentication(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+
+	client := server.Client(friendlycaptcha.WithAPIKey("wrong"), friendlycaptcha.WithStrictMode(true))
+	result := client.VerifyCaptchaResponse(context.Background(), "valid")
+	assert.True(t, result.IsErrorDueToClientError())
+	assert.Equal(t, http.StatusUnauthorized, result.Status)
+	assert.False(t, result.ShouldAccept())
+}
+
+func TestServerDelay(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("slow", friendlycaptchatest.VerifySuccess().WithDelay(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := server.Client().VerifyCaptchaResponse(ctx, "slow")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, result.WasAbleToVerify())
+}
+
+func TestServerRetrieve(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+
+	server.OnRetrieve("token", friendlycaptchatest.RetrieveSuccess(friendlycaptcha.RiskIntelligenceData{
+		RiskScores: null.ValueFrom(friendlycaptcha.RiskScoresData{Overall: friendlycaptcha.RiskScoreHigh}),
+		Client:     friendlycaptcha.ClientData{HeaderUserAgent: "test-agent"},
+	}))
+	client := server.Client()
+
+	result := client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.True(t, result.IsValid())
+	data := result.Response().Data
+	assert.True(t, data.RiskIntelligence.Valid)
+	assert.Equal(t, friendlycaptcha.RiskScoreHigh, data.RiskIntelligence.V.RiskScores.V.Overall)
+	assert.Equal(t, "test-agent", data.RiskIntelligence.V.Client.HeaderUserAgent)
+	assert.Contains(t, string(data.RiskIntelligenceRaw.V), "header_user_agent")
+
+	result = client.RetrieveRiskIntelligence(context.Background(), "unknown")
+	assert.True(t, result.WasAbleToRetrieve())
+	assert.False(t, result.IsValid())
+	assert.Equal(t, friendlycaptcha.ErrorCodeTokenInvalid, result.Response().Error.ErrorCode)
+
+	result = client.RetrieveRiskIntelligence(context.Background(), "")
+	assert.True(t, result.IsErrorDueToClientError())
+	assert.Equal(t, http.StatusBadRequest, result.Status)
+}
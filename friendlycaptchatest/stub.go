@@ -0,0 +1,244 @@
+package friendlycaptchatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/guregu/null/v6"
+)
+
+var (
+	_ friendlycaptcha.Verifier                  = AcceptAll{}
+	_ friendlycaptcha.RiskIntelligenceRetriever = AcceptAll{}
+	_ friendlycaptcha.Verifier                  = RejectAll{}
+	_ friendlycaptcha.RiskIntelligenceRetriever = RejectAll{}
+	_ friendlycaptcha.Verifier                  = FailOpen{}
+	_ friendlycaptcha.RiskIntelligenceRetriever = FailOpen{}
+	_ friendlycaptcha.Verifier                  = (*Scripted)(nil)
+	_ friendlycaptcha.RiskIntelligenceRetriever = (*Scripted)(nil)
+)
+
+// errStubUnavailable is the error that FailOpen results carry.
+var errStubUnavailable = errors.New("friendlycaptchatest: API unavailable")
+
+// AcceptedVerifyResult returns a VerifyResult for a correctly solved captcha.
+func AcceptedVerifyResult() friendlycaptcha.VerifyResult {
+	return friendlycaptcha.NewVerifyResult(friendlycaptcha.VerifyResponse{
+		Success: true,
+		Data: &friendlycaptcha.VerifyResponseData{
+			EventID: "ev_friendlycaptchatest",
+			Challenge: friendlycaptcha.VerifyResponseChallengeData{
+				Timestamp: time.Now().UTC(),
+				Origin:    "http://localhost",
+			},
+		},
+	}, http.StatusOK, false, nil)
+}
+
+// RejectedVerifyResult returns a VerifyResult for a captcha response that the API rejected with the given error
+// code, e.g. ErrorCodeResponseInvalid.
+func RejectedVerifyResult(code friendlycaptcha.ErrorCode) friendlycaptcha.VerifyResult {
+	return friendlycaptcha.NewVerifyResult(friendlycaptcha.VerifyResponse{
+		Success: false,
+		Error:   &friendlycaptcha.VerifyResponseError{ErrorCode: code},
+	}, http.StatusOK, false, nil)
+}
+
+// FailedVerifyResult returns a VerifyResult for a verification that failed because the API could not be reached.
+// `ShouldAccept()` returns true for it unless strict is true.
+func FailedVerifyResult(strict bool) friendlycaptcha.VerifyResult {
+	return friendlycaptcha.NewVerifyResult(
+		friendlycaptcha.VerifyResponse{},
+		-1,
+		strict,
+		fmt.Errorf("%w: %w", friendlycaptcha.ErrVerificationRequest, errStubUnavailable),
+	)
+}
+
+// ValidRetrieveResult returns a RiskIntelligenceRetrieveResult for a valid token with the given risk intelligence.
+func ValidRetrieveResult(data friendlycaptcha.RiskIntelligenceData) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return friendlycaptcha.NewRiskIntelligenceRetrieveResult(friendlycaptcha.RiskIntelligenceRetrieveResponse{
+		Success: true,
+		Data: &friendlycaptcha.RiskIntelligenceRetrieveResponseData{
+			EventID: "ev_friendlycaptchatest",
+			Token: friendlycaptcha.RiskIntelligenceTokenData{
+				Timestamp: time.Now().UTC(),
+				ExpiresAt: time.Now().UTC().Add(time.Hour),
+				NumUses:   1,
+				Origin:    "http://localhost",
+			},
+			RiskIntelligence: null.ValueFrom(data),
+		},
+	}, http.StatusOK, nil)
+}
+
+// InvalidRetrieveResult returns a RiskIntelligenceRetrieveResult for a token that the API rejected with the given
+// error code, e.g. ErrorCodeTokenExpired.
+func InvalidRetrieveResult(code friendlycaptcha.ErrorCode) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return friendlycaptcha.NewRiskIntelligenceRetrieveResult(friendlycaptcha.RiskIntelligenceRetrieveResponse{
+		Success: false,
+		Error:   &friendlycaptcha.VerifyResponseError{ErrorCode: code},
+	}, http.StatusOK, nil)
+}
+
+// FailedRetrieveResult returns a RiskIntelligenceRetrieveResult for a retrieval that failed because the API could not
+// be reached.
+func FailedRetrieveResult() friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return friendlycaptcha.NewRiskIntelligenceRetrieveResult(
+		friendlycaptcha.RiskIntelligenceRetrieveResponse{},
+		-1,
+		fmt.Errorf("%w: %w", friendlycaptcha.ErrRiskIntelligenceRetrieveRequest, errStubUnavailable),
+	)
+}
+
+// AcceptAll is a stub that accepts every captcha response and returns empty risk intelligence for every token.
+type AcceptAll struct{}
+
+// VerifyCaptchaResponse implements friendlycaptcha.Verifier.
+func (AcceptAll) VerifyCaptchaResponse(
+	ctx context.Context,
+	captchaResponse string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.VerifyResult {
+	return AcceptedVerifyResult()
+}
+
+// RetrieveRiskIntelligence implements friendlycaptcha.RiskIntelligenceRetriever.
+func (AcceptAll) RetrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return ValidRetrieveResult(friendlycaptcha.RiskIntelligenceData{})
+}
+
+// RejectAll is a stub that rejects every captcha response as invalid and every token as invalid.
+type RejectAll struct{}
+
+// VerifyCaptchaResponse implements friendlycaptcha.Verifier.
+func (RejectAll) VerifyCaptchaResponse(
+	ctx context.Context,
+	captchaResponse string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.VerifyResult {
+	return RejectedVerifyResult(friendlycaptcha.ErrorCodeResponseInvalid)
+}
+
+// RetrieveRiskIntelligence implements friendlycaptcha.RiskIntelligenceRetriever.
+func (RejectAll) RetrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return InvalidRetrieveResult(friendlycaptcha.ErrorCodeTokenInvalid)
+}
+
+// FailOpen is a stub that behaves as if the Friendly Captcha API is unreachable. Unless Strict is set, `ShouldAccept()`
+// returns true for its results.
+type FailOpen struct {
+	Strict bool
+}
+
+// VerifyCaptchaResponse implements friendlycaptcha.Verifier.
+func (f FailOpen) VerifyCaptchaResponse(
+	ctx context.Context,
+	captchaResponse string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.VerifyResult {
+	return FailedVerifyResult(f.Strict)
+}
+
+// RetrieveRiskIntelligence implements friendlycaptcha.RiskIntelligenceRetriever.
+func (FailOpen) RetrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	return FailedRetrieveResult()
+}
+
+// Scripted is a stub that returns queued results in order. It records the captcha responses and tokens it was
+// called with. It panics when called while its queue is empty, so that unexpected calls fail the test.
+//
+// The zero value is ready to use. A Scripted is safe for concurrent use.
+type Scripted struct {
+	mu              sync.Mutex
+	verifyResults   []friendlycaptcha.VerifyResult
+	retrieveResults []friendlycaptcha.RiskIntelligenceRetrieveResult
+	verifyCalls     []string
+	retrieveCalls   []string
+}
+
+// QueueVerify appends results to be returned by VerifyCaptchaResponse.
+func (s *Scripted) QueueVerify(results ...friendlycaptcha.VerifyResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifyResults = append(s.verifyResults, results...)
+}
+
+// QueueRetrieve appends results to be returned by RetrieveRiskIntelligence.
+func (s *Scripted) QueueRetrieve(results ...friendlycaptcha.RiskIntelligenceRetrieveResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retrieveResults = append(s.retrieveResults, results...)
+}
+
+// VerifyCaptchaResponse implements friendlycaptcha.Verifier.
+func (s *Scripted) VerifyCaptchaResponse(
+	ctx context.Context,
+	captchaResponse string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.VerifyResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifyCalls = append(s.verifyCalls, captchaResponse)
+	if len(s.verifyResults) == 0 {
+		panic(fmt.Sprintf("friendlycaptchatest: unexpected VerifyCaptchaResponse(%q), no results queued", captchaResponse))
+	}
+	result := s.verifyResults[0]
+	s.verifyResults = s.verifyResults[1:]
+	return result
+}
+
+// RetrieveRiskIntelligence implements friendlycaptcha.RiskIntelligenceRetriever.
+func (s *Scripted) RetrieveRiskIntelligence(
+	ctx context.Context,
+	token string,
+	opts ...friendlycaptcha.CallOption,
+) friendlycaptcha.RiskIntelligenceRetrieveResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retrieveCalls = append(s.retrieveCalls, token)
+	if len(s.retrieveResults) == 0 {
+		panic(fmt.Sprintf("friendlycaptchatest: unexpected RetrieveRiskIntelligence(%q), no results queued", token))
+	}
+	result := s.retrieveResults[0]
+	s.retrieveResults = s.retrieveResults[1:]
+	return result
+}
+
+// VerifyCalls returns the captcha responses VerifyCaptchaResponse was called with, in order.
+func (s *Scripted) VerifyCalls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.verifyCalls...)
+}
+
+// RetrieveCalls returns the tokens RetrieveRiskIntelligence was called with, in order.
+func (s *Scripted) RetrieveCalls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.retrieveCalls...)
+}
+
+// Remaining returns the number of queued results that have not been returned yet.
+func (s *Scripted) Remaining() (verify int, retrieve int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.verifyResults), len(s.retrieveResults)
+}
@@ -0,0 +1,88 @@
+package friendlycaptchatest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStubs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	accept := friendlycaptchatest.AcceptAll{}
+	assert.True(t, accept.VerifyCaptchaResponse(ctx, "x").ShouldAccept())
+	assert.True(t, accept.VerifyCaptchaResponse(ctx, "x").WasAbleToVerify())
+	assert.True(t, accept.RetrieveRiskIntelligence(ctx, "x").IsValid())
+
+	reject := friendlycaptchatest.RejectAll{}
+	assert.False(t, reject.VerifyCaptchaResponse(ctx, "x").ShouldAccept())
+	assert.True(t, reject.VerifyCaptchaResponse(ctx, "x").WasAbleToVerify())
+	assert.False(t, reject.RetrieveRiskIntelligence(ctx, "x").IsValid())
+
+	failOpen := friendlycaptchatest.FailOpen{}
+	assert.True(t, failOpen.VerifyCaptchaResponse(ctx, "x").ShouldAccept())
+	assert.False(t, failOpen.VerifyCaptchaResponse(ctx, "x").WasAbleToVerify())
+	assert.True(t, failOpen.VerifyCaptchaResponse(ctx, "x").IsRequestError())
+	assert.True(t, failOpen.RetrieveRiskIntelligence(ctx, "x").IsRequestError())
+
+	failClosed := friendlycaptchatest.FailOpen{Strict: true}
+	assert.False(t, failClosed.VerifyCaptchaResponse(ctx, "x").ShouldAccept())
+}
+
+func TestScripted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	scripted := &friendlycaptchatest.Scripted{}
+	scripted.QueueVerify(
+		friendlycaptchatest.AcceptedVerifyResult(),
+		friendlycaptchatest.RejectedVerifyResult(friendlycaptcha.ErrorCodeResponseDuplicate),
+	)
+	scripted.QueueRetrieve(friendlycaptchatest.InvalidRetrieveResult(friendlycaptcha.ErrorCodeTokenExpired))
+
+	var verifier friendlycaptcha.Verifier = scripted
+	assert.True(t, verifier.VerifyCaptchaResponse(ctx, "first").ShouldAccept())
+	second := verifier.VerifyCaptchaResponse(ctx, "second")
+	assert.False(t, second.ShouldAccept())
+	assert.Equal(t, friendlycaptcha.ErrorCodeResponseDuplicate, second.Response().Error.ErrorCode)
+	assert.Equal(t, []string{"first", "second"}, scripted.VerifyCalls())
+
+	var retriever friendlycaptcha.RiskIntelligenceRetriever = scripted
+	assert.False(t, retriever.RetrieveRiskIntelligence(ctx, "token").IsValid())
+	assert.Equal(t, []string{"token"}, scripted.RetrieveCalls())
+
+	verify, retrieve := scripted.Remaining()
+	assert.Zero(t, verify)
+	assert.Zero(t, retrieve)
+	assert.Panics(t, func() { scripted.VerifyCaptchaResponse(ctx, "third") })
+}
+
+func TestStubsWithMiddleware(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for verifier, expectedStatus := range map[friendlycaptcha.Verifier]int{
+		friendlycaptchatest.AcceptAll{}: http.StatusNoContent,
+		friendlycaptchatest.RejectAll{}: http.StatusForbidden,
+		friendlycaptchatest.FailOpen{}:  http.StatusNoContent,
+	} {
+		form := url.Values{friendlycaptcha.ResponseFormFieldName: {"response"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		friendlycaptcha.NewMiddleware(verifier)(next).ServeHTTP(rec, req)
+		assert.Equal(t, expectedStatus, rec.Code)
+	}
+}
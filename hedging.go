@@ -0,0 +1,117 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	siteverifyPath = "/api/v2/captcha/siteverify"
+	retrievePath   = "/api/v2/riskIntelligence/retrieve"
+)
+
+// WithHedging sends a second, identical request to the Friendly Captcha API if the first one did not respond within
+// the delay, and uses the response that arrives first. This cuts the tail latency of `VerifyCaptchaResponse` when
+// some requests are slow. If multiple endpoints are configured (see WithAPIEndpoints), the second request is sent to
+// the next one. The request that loses is canceled.
+//
+// Both requests verify the same captcha response, so one of them may be rejected with `response_duplicate` because
+// the other one was verified first. Such a response is never used while the other request is still in flight, and
+// if the other request failed, its failure is used instead.
+//
+// `RetrieveRiskIntelligence` is never hedged, since every retrieval counts as a use of the token.
+//
+// This defaults to 0, which disables hedging.
+func WithHedging(delay time.Duration) ClientOption {
+	return func(c *Client) error {
+		if delay < 0 {
+			return fmt.Errorf("hedge delay must not be negative")
+		}
+		c.HedgeDelay = delay
+		return nil
+	}
+}
+
+// hedgeable returns whether requests to the path may be hedged.
+func hedgeable(path string) bool {
+	return path == siteverifyPath
+}
+
+// hedgedResponse is the outcome of one of the requests sent by doRequestHedged.
+type hedgedResponse struct {
+	resp *http.Response
+	body []byte
+	sent int
+	err  error
+}
+
+// usable returns whether the response can be used without waiting for the other request.
+func (r hedgedResponse) usable() bool {
+	return r.err == nil && r.resp.StatusCode < 500 && !isDuplicateResponse(r.body)
+}
+
+// isDuplicateResponse returns whether the body rejects the captcha response with `response_duplicate`.
+func isDuplicateResponse(body []byte) bool {
+	var response VerifyResponse
+	return json.Unmarshal(body, &response) == nil &&
+		response.Error != nil &&
+		response.Error.ErrorCode == ErrorCodeResponseDuplicate
+}
+
+// doRequestHedged is like doRequestWithFailover, but sends a second request, starting at the next endpoint, if the
+// first one did not complete within the hedge delay.
+func (frc *Client) doRequestHedged(
+	ctx context.Context,
+	header http.Header,
+	path string,
+	reqBodyJSON []byte,
+) (*http.Response, []byte, int, error) {
+	if frc.HedgeDelay <= 0 || !hedgeable(path) {
+		return frc.doRequestWithFailover(ctx, 0, header, path, reqBodyJSON)
+	}
+
+	// Canceling the context on return cancels the request that lost.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	responses := make(chan hedgedResponse, 2)
+	send := func(first int) {
+		resp, body, sent, err := frc.doRequestWithFailover(ctx, first, header, path, reqBodyJSON)
+		responses <- hedgedResponse{resp: resp, body: body, sent: sent, err: err}
+	}
+	go send(0)
+
+	timer := time.NewTimer(frc.HedgeDelay)
+	defer timer.Stop()
+
+	var (
+		pending  = 1
+		hedged   = false
+		sent     = 0
+		fallback *hedgedResponse
+	)
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			pending++
+			go send(1)
+		case r := <-responses:
+			pending--
+			sent += r.sent
+			if !hedged || r.usable() {
+				// A request that is still in flight was sent at least once.
+				return r.resp, r.body, sent + pending, r.err
+			}
+			// Prefer a failure over a duplicate: the other request may have caused it.
+			if fallback == nil || isDuplicateResponse(fallback.body) {
+				fallback = &r
+			}
+			if pending == 0 {
+				return fallback.resp, fallback.body, sent, fallback.err
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgingSlowResponse(t *testing.T) {
+	t.Parallel()
+
+	slow, canceled := respondAfter(5*time.Second, respondSuccess)
+	server, requests := newTestServer(t, slow, respondSuccess)
+	client := newTestClient(t, WithHedging(20*time.Millisecond), WithAPIEndpoint(server.URL))
+
+	start := time.Now()
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, result.ShouldAccept())
+	assert.True(t, result.WasAbleToVerify())
+	assert.Equal(t, 2, result.Attempts())
+	assert.Equal(t, int32(2), requests.Load())
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request was not canceled")
+	}
+}
+
+func TestHedgingFastResponse(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, respondSuccess)
+	client := newTestClient(t, WithHedging(20*time.Millisecond), WithAPIEndpoint(server.URL))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.ShouldAccept())
+	assert.Equal(t, 1, result.Attempts())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestHedgingDuplicate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		first    http.HandlerFunc
+		expected FailureClass
+		accepted bool
+	}{
+		// The hedged request was rejected because the first request verified the response.
+		{name: "first succeeds", first: respondSuccess, expected: FailureNone, accepted: true},
+		// The failure policy decides, instead of rejecting the response as a duplicate.
+		{
+			name:     "first fails",
+			first:    respondWithStatus(http.StatusServiceUnavailable),
+			expected: FailureServerError,
+			accepted: true,
+		},
+		{name: "both duplicate", first: respondDuplicate, expected: FailureNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			first, _ := respondAfter(100*time.Millisecond, tt.first)
+			server, requests := newTestServer(t, first, respondDuplicate)
+			client := newTestClient(t, WithHedging(20*time.Millisecond), WithAPIEndpoint(server.URL))
+
+			result := client.VerifyCaptchaResponse(context.Background(), "response")
+			assert.Equal(t, tt.expected, result.FailureClass())
+			assert.Equal(t, tt.accepted, result.ShouldAccept())
+			assert.Equal(t, 2, result.Attempts())
+			assert.Equal(t, int32(2), requests.Load())
+			if tt.expected == FailureNone && !tt.accepted {
+				assert.Equal(t, ErrorCodeResponseDuplicate, result.ErrorCode())
+			}
+		})
+	}
+}
+
+func TestHedgingAlternateEndpoint(t *testing.T) {
+	t.Parallel()
+
+	slow, _ := respondAfter(5*time.Second, respondSuccess)
+	primary, primaryRequests := newTestServer(t, slow)
+	secondary, secondaryRequests := newTestServer(t, respondSuccess)
+	client := newTestClient(t, WithHedging(20*time.Millisecond), WithAPIEndpoints(Endpoint{URL: primary.URL}, Endpoint{URL: secondary.URL}))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.WasAbleToVerify())
+	assert.Equal(t, int32(1), primaryRequests.Load())
+	assert.Equal(t, int32(1), secondaryRequests.Load())
+	// The canceled request says nothing about the health of the primary.
+	assert.Len(t, client.HealthyEndpoints(), 2)
+}
+
+func TestHedgingNotForRetrieve(t *testing.T) {
+	t.Parallel()
+
+	slow, _ := respondAfter(100*time.Millisecond, respondSuccess)
+	server, requests := newTestServer(t, slow)
+	client := newTestClient(t, WithHedging(20*time.Millisecond), WithAPIEndpoint(server.URL))
+
+	result := client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.True(t, result.WasAbleToRetrieve())
+	assert.Equal(t, 1, result.Attempts())
+	assert.Equal(t, int32(1), requests.Load())
+
+	_, err := NewClient(WithAPIKey("test-key"), WithHedging(-time.Second))
+	assert.Error(t, err)
+}
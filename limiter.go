@@ -0,0 +1,207 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ConcurrencyLimiter bounds the number of calls to the Friendly Captcha API that are in flight at the same time, so
+// that a flood of requests to your service doesn't open thousands of connections to the API. Calls that find all
+// slots taken wait in a queue for up to the queue timeout, and then fail with ErrOverloaded.
+//
+// A call holds its slot for all of its requests, including retries and hedged requests.
+//
+// A ConcurrencyLimiter is safe for concurrent use, and can be shared between multiple clients to bound their calls
+// together.
+type ConcurrencyLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+
+	mu     sync.Mutex
+	queued int
+
+	// gaugesMu guards gauges, the registrations of the gauges of the limiter, see observe.
+	gaugesMu sync.Mutex
+	gauges   []*limiterGauges
+}
+
+// limiterGauges is the registration of the gauges of a limiter with a meter, it is shared by all clients that use both.
+type limiterGauges struct {
+	meter        metric.Meter
+	clients      int
+	registration metric.Registration
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter that lets maxInFlight calls through at the same time, other calls
+// wait for up to queueTimeout for a slot. A queueTimeout of 0 fails calls immediately when all slots are taken.
+func NewConcurrencyLimiter(maxInFlight int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &ConcurrencyLimiter{
+		slots:        make(chan struct{}, maxInFlight),
+		queueTimeout: max(queueTimeout, 0),
+	}
+}
+
+// WithMaxInFlight limits the number of calls to the Friendly Captcha API that are in flight at the same time, calls
+// wait for up to queueTimeout for a slot, e.g. `WithMaxInFlight(100, time.Second)`. It is a shorthand for
+// `WithConcurrencyLimiter(NewConcurrencyLimiter(maxInFlight, queueTimeout))`, so every client gets its own limiter. Use
+// WithConcurrencyLimiter to share a limiter between clients, e.g. between the tenants of a Registry.
+//
+// By default the number of calls is not limited.
+func WithMaxInFlight(maxInFlight int, queueTimeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if maxInFlight < 1 {
+			return fmt.Errorf("max in flight must be at least 1")
+		}
+		if queueTimeout < 0 {
+			return fmt.Errorf("queue timeout must not be negative")
+		}
+		c.ConcurrencyLimiter = NewConcurrencyLimiter(maxInFlight, queueTimeout)
+		return nil
+	}
+}
+
+// WithConcurrencyLimiter sets the limiter that bounds the number of calls to the Friendly Captcha API that are in
+// flight at the same time.
+//
+// Calls that could not get a slot in time return without sending a request, with a result for which `IsOverloaded()`
+// is true. The FailurePolicy decides whether they are accepted, see FailurePolicy.Overloaded: by default they are,
+// unless strict mode is enabled.
+//
+// The number of calls in flight and queued are reported as the gauges `friendlycaptcha.client.in_flight` and
+// `friendlycaptcha.client.queued`, see WithMeterProvider, and by `InFlight()` and `Queued()`.
+//
+// By default no concurrency limiter is used.
+func WithConcurrencyLimiter(limiter *ConcurrencyLimiter) ClientOption {
+	return func(c *Client) error {
+		c.ConcurrencyLimiter = limiter
+		return nil
+	}
+}
+
+// InFlight returns the number of calls that are in flight, which is useful for monitoring.
+func (l *ConcurrencyLimiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
+
+// Queued returns the number of calls that wait for a slot, which is useful for monitoring.
+func (l *ConcurrencyLimiter) Queued() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queued
+}
+
+// acquire waits for a slot. It returns ErrOverloaded if no slot became free within the queue timeout, or
+// ErrContextCanceled if the context was done first, unless the call timeout was exceeded, see WithCallTimeout. Every
+// successful acquire must be followed by a call to release.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.queueTimeout == 0 {
+		return ErrOverloaded
+	}
+
+	l.setQueued(+1)
+	defer l.setQueued(-1)
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrOverloaded
+	case <-ctx.Done():
+		if cause := context.Cause(ctx); errors.Is(cause, errCallTimeout) {
+			return fmt.Errorf("error waiting for a free slot: %w", cause)
+		}
+		return fmt.Errorf("%w: %w", ErrContextCanceled, ctx.Err())
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// observe registers the gauges of the limiter with the meter using register, unless another client registered them
+// with the same meter before. The returned function releases the registration for the client, the gauges are
+// unregistered once all clients released them. register returns nil if the gauges could not be registered.
+func (l *ConcurrencyLimiter) observe(meter metric.Meter, register func() metric.Registration) (release func()) {
+	l.gaugesMu.Lock()
+	defer l.gaugesMu.Unlock()
+
+	gauges := l.findGauges(meter)
+	if gauges == nil {
+		registration := register()
+		if registration == nil {
+			return func() {}
+		}
+		gauges = &limiterGauges{meter: meter, registration: registration}
+		l.gauges = append(l.gauges, gauges)
+	}
+	gauges.clients++
+
+	var once sync.Once
+	return func() { once.Do(func() { l.releaseGauges(gauges) }) }
+}
+
+// findGauges returns the registration of the gauges with the meter, if any. Meters that can not be compared are never
+// shared.
+func (l *ConcurrencyLimiter) findGauges(meter metric.Meter) *limiterGauges {
+	if !reflect.TypeOf(meter).Comparable() {
+		return nil
+	}
+	for _, gauges := range l.gauges {
+		if gauges.meter == meter {
+			return gauges
+		}
+	}
+	return nil
+}
+
+func (l *ConcurrencyLimiter) releaseGauges(gauges *limiterGauges) {
+	l.gaugesMu.Lock()
+	defer l.gaugesMu.Unlock()
+
+	gauges.clients--
+	if gauges.clients > 0 {
+		return
+	}
+	_ = gauges.registration.Unregister()
+	for i, other := range l.gauges {
+		if other == gauges {
+			l.gauges = append(l.gauges[:i], l.gauges[i+1:]...)
+			break
+		}
+	}
+}
+
+func (l *ConcurrencyLimiter) setQueued(delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queued += delta
+}
@@ -0,0 +1,190 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// occupySlot starts a call that takes the only slot of the client, and returns its result.
+func occupySlot(t *testing.T, client *Client) <-chan VerifyResult {
+	t.Helper()
+
+	first := make(chan VerifyResult, 1)
+	go func() { first <- client.VerifyCaptchaResponse(context.Background(), "first") }()
+	require.Eventually(t, func() bool { return client.ConcurrencyLimiter.InFlight() == 1 }, time.Second, time.Millisecond)
+	return first
+}
+
+func TestClientMaxInFlightSheds(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server, _ := newTestServer(t, respondWhenReleased(release, respondSuccess), respondSuccess)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithMaxInFlight(1, 0))
+	first := occupySlot(t, client)
+
+	result := client.VerifyCaptchaResponse(context.Background(), "second")
+	assert.True(t, result.IsOverloaded())
+	assert.True(t, result.IsRequestError())
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureOverloaded, result.FailureClass())
+	assert.Equal(t, 0, result.Attempts())
+	assert.True(t, result.ShouldAccept(), "the default failure policy accepts")
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "second", WithCallStrictMode(true)).ShouldAccept())
+	assert.True(t, client.RetrieveRiskIntelligence(context.Background(), "token").IsOverloaded())
+
+	close(release)
+	assert.True(t, (<-first).WasAbleToVerify())
+	assert.Equal(t, 0, client.ConcurrencyLimiter.InFlight())
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "third").WasAbleToVerify())
+}
+
+func TestClientMaxInFlightQueues(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	release := make(chan struct{})
+	server, _ := newTestServer(t, respondWhenReleased(release, respondSuccess), respondSuccess)
+	client := newTestClient(t,
+		WithAPIEndpoint(server.URL),
+		WithMaxInFlight(1, time.Minute),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	first := occupySlot(t, client)
+
+	second := make(chan VerifyResult, 1)
+	go func() { second <- client.VerifyCaptchaResponse(context.Background(), "second") }()
+	require.Eventually(t, func() bool { return client.ConcurrencyLimiter.Queued() == 1 }, time.Second, time.Millisecond)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	gauges := make(map[string]int64)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok {
+			gauges[m.Name] = gauge.DataPoints[0].Value
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"friendlycaptcha.client.in_flight": 1,
+		"friendlycaptcha.client.queued":    1,
+	}, gauges)
+
+	close(release)
+	assert.True(t, (<-first).WasAbleToVerify())
+	assert.True(t, (<-second).WasAbleToVerify())
+	assert.Equal(t, 0, client.ConcurrencyLimiter.Queued())
+}
+
+func TestClientMaxInFlightQueueTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	server, _ := newTestServer(t, respondWhenReleased(release, respondSuccess), respondSuccess)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithMaxInFlight(1, 20*time.Millisecond))
+	occupySlot(t, client)
+
+	start := time.Now()
+	result := client.VerifyCaptchaResponse(context.Background(), "second")
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.True(t, result.IsOverloaded())
+	assert.Equal(t, 0, client.ConcurrencyLimiter.Queued())
+
+	// A caller that gives up while queued is not overloaded.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	result = client.VerifyCaptchaResponse(ctx, "third", WithCallStrictMode(false))
+	assert.False(t, result.IsOverloaded())
+	assert.True(t, result.IsContextCanceled())
+	assert.False(t, result.ShouldAccept())
+}
+
+func TestClientMaxInFlightCallTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	server, _ := newTestServer(t, respondWhenReleased(release, respondSuccess), respondSuccess)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithMaxInFlight(1, time.Minute))
+	occupySlot(t, client)
+
+	// Time spent in the queue counts against the call timeout.
+	start := time.Now()
+	result := client.VerifyCaptchaResponse(context.Background(), "second", WithCallTimeout(20*time.Millisecond))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, result.IsOverloaded())
+	assert.False(t, result.IsContextCanceled())
+	assert.Equal(t, FailureTimeout, result.FailureClass())
+	assert.Equal(t, 0, client.ConcurrencyLimiter.Queued())
+}
+
+func TestMaxInFlightOptions(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewClient(WithAPIKey("test-key"), WithMaxInFlight(0, time.Second))
+	assert.Error(t, err)
+	_, err = NewClient(WithAPIKey("test-key"), WithMaxInFlight(1, -time.Second))
+	assert.Error(t, err)
+
+	// A limiter can be shared between clients.
+	limiter := NewConcurrencyLimiter(10, time.Second)
+	a, err := NewClient(WithAPIKey("a"), WithConcurrencyLimiter(limiter))
+	require.NoError(t, err)
+	b, err := NewClient(WithAPIKey("b"), WithConcurrencyLimiter(limiter))
+	require.NoError(t, err)
+	assert.Same(t, a.ConcurrencyLimiter, b.ConcurrencyLimiter)
+
+	// Without a limiter calls are not limited.
+	var none *ConcurrencyLimiter
+	assert.NoError(t, none.acquire(context.Background()))
+	assert.Equal(t, 0, none.InFlight())
+	assert.Equal(t, 0, none.Queued())
+}
+
+// gaugeClients returns the number of clients that report the gauges of the limiter, per registration.
+func gaugeClients(limiter *ConcurrencyLimiter) []int {
+	limiter.gaugesMu.Lock()
+	defer limiter.gaugesMu.Unlock()
+	clients := []int{}
+	for _, gauges := range limiter.gauges {
+		clients = append(clients, gauges.clients)
+	}
+	return clients
+}
+
+func TestSharedLimiterGauges(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	limiter := NewConcurrencyLimiter(10, time.Second)
+	a, err := NewClient(WithAPIKey("a"), WithMeterProvider(meterProvider), WithConcurrencyLimiter(limiter))
+	require.NoError(t, err)
+	b, err := NewClient(WithAPIKey("b"), WithMeterProvider(meterProvider), WithConcurrencyLimiter(limiter))
+	require.NoError(t, err)
+
+	// The gauges of a shared limiter are registered once per meter.
+	assert.Equal(t, []int{2}, gaugeClients(limiter))
+
+	// They are unregistered once all clients released them, releasing twice has no effect.
+	a.release()
+	a.release()
+	assert.Equal(t, []int{1}, gaugeClients(limiter))
+	b.release()
+	assert.Empty(t, gaugeClients(limiter))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			_, isGauge := m.Data.(metricdata.Gauge[int64])
+			assert.False(t, isGauge, m.Name)
+		}
+	}
+}
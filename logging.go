@@ -0,0 +1,146 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	logMessageVerify   = "friendlycaptcha: verified captcha response"
+	logMessageRetrieve = "friendlycaptcha: retrieved risk intelligence"
+)
+
+// WithLogger sets the logger that receives a structured log record for every call to `VerifyCaptchaResponse` and
+// `RetrieveRiskIntelligence`. The record contains the outcome, HTTP status, error code, event ID and latency of the
+// call. The API key and the captcha response are never logged.
+//
+// Successful calls are logged at debug level, rejected captcha responses and invalid tokens at info level, calls that
+// could not be completed at warn level and client errors (e.g. `auth_invalid`) at error level.
+//
+// This defaults to nil, which disables logging.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) error {
+		c.Logger = logger
+		return nil
+	}
+}
+
+// logLevel returns the level for a call with the given outcome and class of failure.
+func logLevel(outcome string, failure FailureClass) slog.Level {
+	switch {
+	case failure == FailureClientError, failure == FailureCreatingRequest, failure == FailureUnknown:
+		return slog.LevelError
+	case failure == FailureContextCanceled:
+		// The caller gave up, there is nothing wrong with the API or the configuration.
+		return slog.LevelInfo
+	case failure != FailureNone:
+		return slog.LevelWarn
+	case outcome == outcomeAccepted, outcome == outcomeValid:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (frc *Client) logVerify(ctx context.Context, result VerifyResult, elapsed time.Duration) {
+	if frc.Logger == nil {
+		return
+	}
+	outcome := verifyOutcome(result)
+	level := logLevel(outcome, result.FailureClass())
+	if !frc.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := append([]slog.Attr{
+		slog.String("operation", operationVerify),
+		slog.String("outcome", outcome),
+		slog.Duration("duration", elapsed),
+	}, result.logAttrs()...)
+	frc.Logger.LogAttrs(ctx, level, logMessageVerify, attrs...)
+}
+
+func (frc *Client) logRetrieve(ctx context.Context, result RiskIntelligenceRetrieveResult, elapsed time.Duration) {
+	if frc.Logger == nil {
+		return
+	}
+	outcome := retrieveOutcome(result)
+	level := logLevel(outcome, result.FailureClass())
+	if !frc.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := append([]slog.Attr{
+		slog.String("operation", operationRetrieve),
+		slog.String("outcome", outcome),
+		slog.Duration("duration", elapsed),
+	}, result.logAttrs()...)
+	frc.Logger.LogAttrs(ctx, level, logMessageRetrieve, attrs...)
+}
+
+// LogValue implements slog.LogValuer, so a VerifyResult can be passed to a logger as-is.
+func (r VerifyResult) LogValue() slog.Value {
+	return slog.GroupValue(r.logAttrs()...)
+}
+
+func (r VerifyResult) logAttrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Bool("success", r.Success),
+		slog.Int("status", r.Status),
+		slog.Bool("was_able_to_verify", r.WasAbleToVerify()),
+	}
+	// ShouldAccept is undefined for the zero value.
+	if r.WasAbleToVerify() || r.err != nil {
+		shouldAccept := r.ShouldAccept()
+		attrs = append(attrs,
+			slog.Bool("should_accept", shouldAccept),
+			slog.Bool("fail_open", shouldAccept && !r.WasAbleToVerify()),
+		)
+	}
+	if r.attempts > 0 {
+		attrs = append(attrs, slog.Int("attempts", r.attempts))
+	}
+	if code := r.ErrorCode(); code != "" {
+		attrs = append(attrs, slog.String("error_code", string(code)))
+	}
+	if data := r.response.Data; data != nil && data.EventID != "" {
+		attrs = append(attrs, slog.String("event_id", data.EventID))
+	}
+	if failure := r.FailureClass(); failure != FailureNone {
+		attrs = append(attrs, slog.String("failure_class", string(failure)))
+	}
+	if r.err != nil {
+		attrs = append(attrs, slog.String("error", r.err.Error()))
+	}
+	return attrs
+}
+
+// LogValue implements slog.LogValuer, so a RiskIntelligenceRetrieveResult can be passed to a logger as-is.
+func (r RiskIntelligenceRetrieveResult) LogValue() slog.Value {
+	return slog.GroupValue(r.logAttrs()...)
+}
+
+func (r RiskIntelligenceRetrieveResult) logAttrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Bool("success", r.Success),
+		slog.Int("status", r.Status),
+		slog.Bool("is_valid", r.IsValid()),
+	}
+	if r.attempts > 0 {
+		attrs = append(attrs, slog.Int("attempts", r.attempts))
+	}
+	if code := r.ErrorCode(); code != "" {
+		attrs = append(attrs, slog.String("error_code", string(code)))
+	}
+	if data := r.response.Data; data != nil && data.EventID != "" {
+		attrs = append(attrs, slog.String("event_id", data.EventID))
+	}
+	if failure := r.FailureClass(); failure != FailureNone {
+		attrs = append(attrs, slog.String("failure_class", string(failure)))
+	}
+	if r.err != nil {
+		attrs = append(attrs, slog.String("error", r.err.Error()))
+	}
+	return attrs
+}
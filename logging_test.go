@@ -0,0 +1,160 @@
+package friendlycaptcha_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	t.Cleanup(server.Close)
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+	server.OnVerify("invalid", friendlycaptchatest.Error(friendlycaptcha.ErrorCodeResponseInvalid))
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+	server.OnRetrieve("token", friendlycaptchatest.RetrieveSuccess(friendlycaptcha.RiskIntelligenceData{}))
+
+	tests := []struct {
+		name   string
+		call   func(client *friendlycaptcha.Client)
+		apiKey string
+		level  string
+		attrs  map[string]any
+	}{
+		{
+			name:  "accepted",
+			call:  func(client *friendlycaptcha.Client) { client.VerifyCaptchaResponse(context.Background(), "valid") },
+			level: "DEBUG",
+			attrs: map[string]any{
+				"operation":     "siteverify",
+				"outcome":       "accepted",
+				"status":        float64(200),
+				"event_id":      "ev_friendlycaptchatest",
+				"should_accept": true,
+				"fail_open":     false,
+			},
+		},
+		{
+			name:  "rejected",
+			call:  func(client *friendlycaptcha.Client) { client.VerifyCaptchaResponse(context.Background(), "invalid") },
+			level: "INFO",
+			attrs: map[string]any{
+				"outcome":       "rejected",
+				"error_code":    "response_invalid",
+				"should_accept": false,
+			},
+		},
+		{
+			name:  "failed open",
+			call:  func(client *friendlycaptcha.Client) { client.VerifyCaptchaResponse(context.Background(), "outage") },
+			level: "WARN",
+			attrs: map[string]any{
+				"outcome":       "failed_open",
+				"status":        float64(503),
+				"failure_class": "server_error",
+				"fail_open":     true,
+			},
+		},
+		{
+			name:   "client error",
+			call:   func(client *friendlycaptcha.Client) { client.VerifyCaptchaResponse(context.Background(), "valid") },
+			apiKey: "secret-revoked-api-key",
+			level:  "ERROR",
+			attrs: map[string]any{
+				"status":        float64(401),
+				"error_code":    "auth_invalid",
+				"failure_class": "client_error",
+			},
+		},
+		{
+			name: "retrieve",
+			call: func(client *friendlycaptcha.Client) {
+				client.RetrieveRiskIntelligence(context.Background(), "token")
+			},
+			level: "DEBUG",
+			attrs: map[string]any{
+				"operation": "risk_intelligence_retrieve",
+				"outcome":   "valid",
+				"is_valid":  true,
+				"event_id":  "ev_friendlycaptchatest",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			opts := []friendlycaptcha.ClientOption{friendlycaptcha.WithLogger(logger)}
+			if tt.apiKey != "" {
+				opts = append(opts, friendlycaptcha.WithAPIKey(tt.apiKey))
+			}
+
+			tt.call(server.Client(opts...))
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 1)
+			var record map[string]any
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+
+			assert.Equal(t, tt.level, record["level"])
+			assert.Contains(t, record, "duration")
+			for key, value := range tt.attrs {
+				assert.Equal(t, value, record[key], key)
+			}
+			assert.NotContains(t, buf.String(), friendlycaptchatest.APIKey)
+			if tt.apiKey != "" {
+				assert.NotContains(t, buf.String(), tt.apiKey)
+			}
+		})
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	client := server.Client(friendlycaptcha.WithLogger(logger))
+
+	client.VerifyCaptchaResponse(context.Background(), "valid")
+	assert.Empty(t, buf.String())
+}
+
+func TestResultLogValue(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	logger.Info("verify", "result", friendlycaptchatest.AcceptedVerifyResult())
+	assert.Contains(t, buf.String(), "result.success=true result.status=200")
+	assert.Contains(t, buf.String(), "result.should_accept=true")
+
+	buf.Reset()
+	logger.Info("verify", "result", friendlycaptcha.VerifyResult{})
+	assert.Contains(t, buf.String(), "result.was_able_to_verify=false")
+	assert.NotContains(t, buf.String(), "should_accept")
+
+	buf.Reset()
+	logger.Info("retrieve", "result", friendlycaptchatest.InvalidRetrieveResult(friendlycaptcha.ErrorCodeTokenInvalid))
+	assert.Contains(t, buf.String(), "result.is_valid=false")
+	assert.Contains(t, buf.String(), "result.error_code=token_invalid")
+}
@@ -0,0 +1,162 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// A MiddlewareOption is a function that can be passed to Client.Middleware to configure the middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// A RejectionHandler is called by the middleware when a captcha response should not be accepted.
+// The VerifyResult that led to the rejection is passed along so it can be logged or inspected.
+type RejectionHandler func(w http.ResponseWriter, r *http.Request, result VerifyResult)
+
+type middlewareConfig struct {
+	fieldName        string
+	methods          map[string]bool
+	rejectionHandler RejectionHandler
+	checkOrigin      bool
+	callOptions      []CallOption
+}
+
+type verifyResultContextKey struct{}
+
+// DefaultRejectionHandler responds with a plain text 403 Forbidden.
+func DefaultRejectionHandler(w http.ResponseWriter, r *http.Request, result VerifyResult) {
+	http.Error(w, "Anti-robot check failed, please try again.", http.StatusForbidden)
+}
+
+// Middleware returns net/http middleware that verifies the captcha response submitted with the request before
+// calling the next handler.
+//
+// The captcha response is read from the form field named `frc-captcha-response` (see ResponseFormFieldName), both
+// URL-encoded and multipart forms are supported. If `ShouldAccept()` returns false for the result the rejection
+// handler is called instead of the next handler. Otherwise the VerifyResult is stored in the request context and can
+// be retrieved with VerifyResultFromContext. Requests without a captcha response are always rejected, also when strict
+// mode is disabled: the result's `RequestError()` is ErrResponseMissing.
+//
+// By default only POST, PUT, PATCH and DELETE requests are verified, other requests are passed through as-is.
+func (frc *Client) Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return NewMiddleware(frc, opts...)
+}
+
+// NewMiddleware is like Client.Middleware, but verifies captcha responses with the given Verifier. This is useful to
+// test handlers with one of the stub verifiers from the friendlycaptchatest package.
+func NewMiddleware(verifier Verifier, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return newMiddleware(func(r *http.Request) (Verifier, error) { return verifier, nil }, opts)
+}
+
+// newMiddleware returns middleware that verifies captcha responses with the Verifier returned by resolve for the
+// request. If resolve returns an error, the request is rejected with a result with that error.
+func newMiddleware(
+	resolve func(r *http.Request) (Verifier, error),
+	opts []MiddlewareOption,
+) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{
+		fieldName:        ResponseFormFieldName,
+		rejectionHandler: DefaultRejectionHandler,
+	}
+	WithVerifiedMethods(http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)(cfg)
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.methods[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			verifier, err := resolve(r)
+			if err != nil {
+				cfg.rejectionHandler(w, r, VerifyResult{Status: -1, failure: FailureUnknown, err: err})
+				return
+			}
+
+			// PostFormValue parses both URL-encoded and multipart bodies, and ignores the query string.
+			captchaResponse := r.PostFormValue(cfg.fieldName)
+			if captchaResponse == "" {
+				// The API rejects a missing response as a client error, which the FailurePolicy may accept.
+				cfg.rejectionHandler(w, r, VerifyResult{Status: -1, failure: FailureUnknown, err: ErrResponseMissing})
+				return
+			}
+			callOptions := cfg.callOptions
+			if cfg.checkOrigin {
+				callOptions = append(callOptions[:len(callOptions):len(callOptions)], withCallRequestOrigin(r))
+			}
+			result := verifier.VerifyCaptchaResponse(r.Context(), captchaResponse, callOptions...)
+			if !result.ShouldAccept() {
+				cfg.rejectionHandler(w, r, result)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), verifyResultContextKey{}, result)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// VerifyResultFromContext returns the VerifyResult stored in the context by Client.Middleware.
+// The second return value is false if the middleware did not verify the request (e.g. because of its method).
+func VerifyResultFromContext(ctx context.Context) (VerifyResult, bool) {
+	result, ok := ctx.Value(verifyResultContextKey{}).(VerifyResult)
+	return result, ok
+}
+
+// WithResponseFieldName sets the name of the form field the middleware reads the captcha response from.
+//
+// This defaults to `frc-captcha-response`.
+func WithResponseFieldName(fieldName string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.fieldName = fieldName
+	}
+}
+
+// WithVerifiedMethods sets the HTTP methods for which the middleware verifies the captcha response, requests with
+// other methods are passed through without verification.
+//
+// This defaults to POST, PUT, PATCH and DELETE.
+func WithVerifiedMethods(methods ...string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.methods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			cfg.methods[strings.ToUpper(method)] = true
+		}
+	}
+}
+
+// WithRejectionHandler sets the handler that is called when the captcha response should not be accepted.
+//
+// This defaults to DefaultRejectionHandler.
+func WithRejectionHandler(handler RejectionHandler) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.rejectionHandler = handler
+	}
+}
+
+// WithRequestOriginCheck rejects captcha responses of challenges that were not solved on the origin of the request:
+// the `Origin` header of the request if it has one, otherwise its `Host` header. This prevents that a captcha
+// response solved on another site with the same sitekey is submitted to this one, without having to configure the
+// allowed origins with WithAllowedOrigins. Rejected results return true for `IsOriginNotAllowed()`.
+//
+// The check is made by the Client, so that logs, metrics and events report the rejection. The stub verifiers of the
+// friendlycaptchatest package ignore it.
+//
+// Don't use this behind a reverse proxy that rewrites the `Host` header of requests without an `Origin` header.
+func WithRequestOriginCheck() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.checkOrigin = true
+	}
+}
+
+// WithCallOptions sets the CallOptions the middleware verifies captcha responses with, e.g. WithCallStrictMode(true) to
+// use strict mode only for the routes behind this middleware.
+func WithCallOptions(opts ...CallOption) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.callOptions = opts
+	}
+}
@@ -0,0 +1,148 @@
+package friendlycaptcha
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func multipartBody(t *testing.T, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatalf("failed to write multipart field: %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+	return body, mw.FormDataContentType()
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithStrictMode(true))
+
+	var gotResult VerifyResult
+	var gotOK bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotResult, gotOK = VerifyResultFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := client.Middleware()(next)
+
+	t.Run("urlencoded accepted", func(t *testing.T) {
+		form := url.Values{ResponseFormFieldName: {"valid"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, gotOK)
+		assert.True(t, gotResult.ShouldAccept())
+		assert.Equal(t, "ev_test", gotResult.Response().Data.EventID)
+	})
+
+	t.Run("multipart accepted", func(t *testing.T) {
+		body, contentType := multipartBody(t, map[string]string{ResponseFormFieldName: "valid"})
+		req := httptest.NewRequest(http.MethodPost, "/", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("invalid response rejected", func(t *testing.T) {
+		form := url.Values{ResponseFormFieldName: {"invalid"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("GET passes through", func(t *testing.T) {
+		gotOK = true
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.False(t, gotOK)
+	})
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithStrictMode(true))
+
+	var rejected VerifyResult
+	handler := client.Middleware(
+		WithResponseFieldName("captcha"),
+		WithVerifiedMethods("get"),
+		WithRejectionHandler(func(w http.ResponseWriter, r *http.Request, result VerifyResult) {
+			rejected = result
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The query string is never used as a source for the captcha response.
+	req := httptest.NewRequest(http.MethodGet, "/?captcha=valid", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.ErrorIs(t, rejected.RequestError(), ErrResponseMissing)
+
+	form := url.Values{"captcha": {"valid"}}
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, "POST is not verified when only GET is configured")
+}
+
+func TestMiddlewareMissingResponse(t *testing.T) {
+	t.Parallel()
+
+	// Without strict mode, the API's rejection of a missing response would be accepted as a client error.
+	server, requests := newTestServer(t)
+	client := newTestClient(t, WithAPIEndpoint(server.URL))
+
+	var rejected VerifyResult
+	handler := client.Middleware(WithRejectionHandler(func(w http.ResponseWriter, r *http.Request, result VerifyResult) {
+		rejected = result
+		w.WriteHeader(http.StatusForbidden)
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, form := range []url.Values{{}, {ResponseFormFieldName: {""}}} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.ErrorIs(t, rejected.RequestError(), ErrResponseMissing)
+		assert.False(t, rejected.ShouldAccept())
+	}
+	assert.Equal(t, int32(0), requests.Load())
+}
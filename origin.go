@@ -0,0 +1,140 @@
+package friendlycaptcha
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WithAllowedOrigins only accepts captcha responses of challenges that were solved on one of the given origins, see
+// `VerifyResponseChallengeData.Origin`. This prevents that a captcha response solved on another site with the same
+// sitekey, e.g. a staging environment, is accepted.
+//
+// An origin is either exact, e.g. "https://example.com", or a wildcard for all subdomains, e.g.
+// "https://*.example.com", which does not match "https://example.com" itself. Origins without a scheme, e.g.
+// "example.com", match any scheme. Ports must match exactly. Origins are compared case-insensitively.
+//
+// If the origin of a successfully verified captcha response is not allowed, the result is rejected: `ShouldAccept()`
+// returns false and `IsOriginNotAllowed()` returns true. Responses that could not be verified are not affected.
+//
+// This defaults to nil, which allows all origins.
+func WithAllowedOrigins(origins ...string) ClientOption {
+	return func(c *Client) error {
+		for _, origin := range origins {
+			if _, err := parseOriginPattern(origin); err != nil {
+				return err
+			}
+		}
+		c.AllowedOrigins = origins
+		return nil
+	}
+}
+
+// originPattern is a parsed allowed origin.
+type originPattern struct {
+	// scheme is empty if any scheme matches.
+	scheme string
+	// host is the host including the port, for wildcards without the "*." prefix.
+	host     string
+	wildcard bool
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	var p originPattern
+	rest := strings.ToLower(strings.TrimSuffix(origin, "/"))
+	if scheme, host, ok := strings.Cut(rest, "://"); ok {
+		if scheme == "" {
+			return p, fmt.Errorf("invalid allowed origin %q: empty scheme", origin)
+		}
+		p.scheme, rest = scheme, host
+	}
+	if host, ok := strings.CutPrefix(rest, "*."); ok {
+		p.wildcard, rest = true, host
+	}
+	if rest == "" || strings.ContainsAny(rest, "/*?#@ ") {
+		return p, fmt.Errorf("invalid allowed origin %q: must be a scheme and host, e.g. \"https://example.com\" "+
+			"or \"https://*.example.com\"", origin)
+	}
+	p.host = rest
+	return p, nil
+}
+
+func (p originPattern) matches(scheme, host string) bool {
+	if p.scheme != "" && p.scheme != scheme {
+		return false
+	}
+	if p.wildcard {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// splitOrigin returns the lowercase scheme and host of an origin, e.g. "https" and "example.com:8443" for
+// "https://example.com:8443". It returns false if the value is not an origin.
+func splitOrigin(origin string) (string, string, bool) {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return "", "", false
+	}
+	return strings.ToLower(u.Scheme), strings.ToLower(u.Host), true
+}
+
+// originAllowed returns whether the origin matches one of the allowed origins.
+func originAllowed(allowed []string, origin string) bool {
+	scheme, host, ok := splitOrigin(origin)
+	if !ok {
+		return false
+	}
+	for _, a := range allowed {
+		p, err := parseOriginPattern(a)
+		if err == nil && p.matches(scheme, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOriginMatches returns whether the origin is the origin of the request: its `Origin` header if it has one,
+// otherwise its `Host` header, in which case the scheme is not compared.
+func requestOriginMatches(r *http.Request, origin string) bool {
+	scheme, host, ok := splitOrigin(origin)
+	if !ok {
+		return false
+	}
+	if requestOrigin := r.Header.Get("Origin"); requestOrigin != "" && requestOrigin != "null" {
+		requestScheme, requestHost, ok := splitOrigin(requestOrigin)
+		return ok && requestScheme == scheme && requestHost == host
+	}
+	return r.Host != "" && strings.ToLower(r.Host) == host
+}
+
+// checkOrigin rejects a successfully verified result if the challenge was not solved on one of the allowed origins of
+// the call, or not on the origin of its request (see WithRequestOriginCheck).
+func (frc *Client) checkOrigin(result *VerifyResult, cfg *callConfig) {
+	if !result.WasAbleToVerify() || !result.Success {
+		return
+	}
+	origin := result.challengeOrigin()
+	if len(cfg.allowedOrigins) > 0 && !originAllowed(cfg.allowedOrigins, origin) {
+		result.reject(fmt.Errorf("%w: %q", ErrOriginNotAllowed, origin))
+		return
+	}
+	if cfg.originRequest != nil && !requestOriginMatches(cfg.originRequest, origin) {
+		result.reject(fmt.Errorf("%w: %q does not match the request", ErrOriginNotAllowed, origin))
+	}
+}
+
+// challengeOrigin returns the origin the challenge was solved on, if the captcha response was verified.
+func (r VerifyResult) challengeOrigin() string {
+	if r.response.Data == nil {
+		return ""
+	}
+	return r.response.Data.Challenge.Origin
+}
+
+// reject marks a successfully verified result as rejected for the given reason.
+func (r *VerifyResult) reject(err error) {
+	r.Success = false
+	r.err = err
+}
@@ -0,0 +1,191 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		allowed  string
+		origin   string
+		expected bool
+	}{
+		{allowed: "https://example.com", origin: "https://example.com", expected: true},
+		{allowed: "https://example.com/", origin: "https://EXAMPLE.com", expected: true},
+		{allowed: "HTTPS://Example.com", origin: "https://example.com", expected: true},
+		{allowed: "https://example.com", origin: "http://example.com", expected: false},
+		{allowed: "https://example.com", origin: "https://www.example.com", expected: false},
+		{allowed: "https://example.com", origin: "https://example.com:8443", expected: false},
+		{allowed: "https://example.com:8443", origin: "https://example.com:8443", expected: true},
+		{allowed: "example.com", origin: "http://example.com", expected: true},
+		{allowed: "example.com", origin: "https://example.com", expected: true},
+		{allowed: "https://*.example.com", origin: "https://www.example.com", expected: true},
+		{allowed: "https://*.example.com", origin: "https://a.b.example.com", expected: true},
+		{allowed: "https://*.example.com", origin: "https://example.com", expected: false},
+		{allowed: "https://*.example.com", origin: "https://badexample.com", expected: false},
+		{allowed: "*.example.com", origin: "http://www.example.com", expected: true},
+		{allowed: "https://example.com", origin: "", expected: false},
+		{allowed: "https://example.com", origin: "null", expected: false},
+		{allowed: "https://example.com", origin: "https://user@example.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, originAllowed([]string{tt.allowed}, tt.origin), "%q %q", tt.allowed, tt.origin)
+	}
+	assert.True(t, originAllowed([]string{"https://example.com", "https://example.org"}, "https://example.org"))
+	assert.False(t, originAllowed(nil, "https://example.com"))
+}
+
+func TestWithAllowedOriginsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, origin := range []string{"", "https://", "://example.com", "https://example.com/path", "https://*",
+		"https://www.*.com", "https://example.com?a=b", "https://user@example.com", "example .com"} {
+		_, err := NewClient(WithAPIKey("test-key"), WithAllowedOrigins("https://example.com", origin))
+		assert.Error(t, err, origin)
+	}
+}
+
+func TestClientWithAllowedOrigins(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	tests := []struct {
+		allowed  []string
+		response string
+		accepted bool
+	}{
+		{allowed: nil, response: "valid", accepted: true},
+		{allowed: []string{"https://example.com"}, response: "valid", accepted: true},
+		{allowed: []string{"https://example.org", "https://*.example.com"}, response: "valid", accepted: false},
+		{allowed: []string{"https://example.org"}, response: "invalid", accepted: false},
+	}
+
+	for _, tt := range tests {
+		client, err := NewClient(
+			WithAPIKey("test-key"),
+			WithAPIEndpoint(server.URL),
+			WithAllowedOrigins(tt.allowed...),
+		)
+		require.NoError(t, err)
+
+		result := client.VerifyCaptchaResponse(context.Background(), tt.response)
+		assert.True(t, result.WasAbleToVerify())
+		assert.Equal(t, tt.accepted, result.ShouldAccept(), "%v %s", tt.allowed, tt.response)
+		assert.Equal(t, tt.accepted, result.Success)
+		assert.Equal(t, FailureNone, result.FailureClass())
+		// Only successfully verified responses are rejected because of their origin.
+		rejected := !tt.accepted && tt.response == "valid"
+		assert.Equal(t, rejected, result.IsOriginNotAllowed())
+		if rejected {
+			assert.ErrorContains(t, result.RequestError(), `"https://example.com"`)
+			assert.True(t, result.Response().Success, "the response of the API is kept")
+			assert.Equal(t, outcomeRejected, verifyOutcome(result))
+		}
+	}
+}
+
+func TestClientWithAllowedOriginsDuringOutage(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithAllowedOrigins("https://example.org"),
+	)
+	require.NoError(t, err)
+
+	// The origin is unknown if the response could not be verified, the failure policy decides.
+	result := client.VerifyCaptchaResponse(context.Background(), "valid")
+	assert.False(t, result.IsOriginNotAllowed())
+	assert.True(t, result.ShouldAccept())
+}
+
+func TestMiddlewareWithRequestOriginCheck(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithStrictMode(true))
+	handler := client.Middleware(WithRequestOriginCheck())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	tests := []struct {
+		name     string
+		host     string
+		origin   string
+		response string
+		expected int
+	}{
+		{name: "same origin", host: "example.com", origin: "https://example.com", response: "valid", expected: http.StatusOK},
+		{name: "host only", host: "example.com", response: "valid", expected: http.StatusOK},
+		{name: "null origin", host: "Example.com", origin: "null", response: "valid", expected: http.StatusOK},
+		{name: "other origin", host: "example.com", origin: "https://example.org", response: "valid", expected: http.StatusForbidden},
+		{name: "other scheme", host: "example.com", origin: "http://example.com", response: "valid", expected: http.StatusForbidden},
+		{name: "other host", host: "example.org", response: "valid", expected: http.StatusForbidden},
+		{name: "invalid response", host: "example.com", origin: "https://example.com", response: "invalid", expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{ResponseFormFieldName: {tt.response}}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
+
+type lastEventSink struct {
+	event Event
+}
+
+func (s *lastEventSink) Record(event Event) {
+	s.event = event
+}
+
+func TestMiddlewareWithRequestOriginCheckIsRecorded(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithStrictMode(true))
+	sink := &lastEventSink{}
+	client.EventSink = sink
+	var rejected VerifyResult
+	handler := client.Middleware(
+		WithRequestOriginCheck(),
+		WithRejectionHandler(func(w http.ResponseWriter, r *http.Request, result VerifyResult) {
+			rejected = result
+			DefaultRejectionHandler(w, r, result)
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	form := url.Values{ResponseFormFieldName: {"valid"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://example.org")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.True(t, rejected.IsOriginNotAllowed())
+	// The audit trail reports the rejection made for the middleware.
+	assert.Equal(t, "rejected", sink.event.Outcome)
+}
@@ -0,0 +1,399 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentError is an error in a policy document, with its location in the source.
+type DocumentError struct {
+	// Source is the name of the document, usually its path.
+	Source string
+	// Line and Column are 1-based, they are 0 if the location is unknown.
+	Line   int
+	Column int
+	// Message describes the error.
+	Message string
+}
+
+func (e *DocumentError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.Source, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", e.Source, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.Source, e.Line, e.Column, e.Message)
+}
+
+// LoadFile reads and parses the policy document at the given path, see ParseDocument.
+func LoadFile(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	return ParseDocument(path, data)
+}
+
+// ParseDocument parses a policy document in YAML or JSON. The source is used in error messages, usually it is the
+// path of the document.
+//
+// A policy document describes a Policy in YAML or JSON (which is a subset of YAML):
+//
+//	default: allow
+//	rules:
+//	  - name: tor
+//	    when: {tor: true}
+//	    decision: deny
+//	  - name: high-risk
+//	    when: {overall_risk_at_least: high}
+//	    decision: review
+//	  - name: hosting-outside-eu
+//	    when:
+//	      all:
+//	        - as_type_in: [hosting]
+//	        - not: {country_in: [DE, NL, FR]}
+//	    decision: challenge
+//
+// Every condition is a mapping with a single key:
+//
+//   - all, any: a list of conditions, see And and Or.
+//   - not: a condition, see Not.
+//   - always: true, see Always.
+//   - tor, icloud_private_relay, automation_tool_detected, known_bot_detected, time_zone_country_mismatch,
+//     has_risk_scores: true, or false for the negation.
+//   - overall_risk_at_least, network_risk_at_least, browser_risk_at_least, vpn_score_at_least,
+//     proxy_score_at_least: a risk score from 1 to 5, or one of very_low, low, medium, high and very_high.
+//   - country_in, as_type_in, device_type_in, browser_in: a list of strings.
+//   - asn_in: a list of autonomous system numbers.
+//   - expr: a CEL expression that evaluates to a bool, see Expression.
+//
+// The whole document is validated before it is compiled into a Policy. If it is invalid, all errors found are
+// returned joined together, use errors.As with a *DocumentError to get the location of the first one.
+func ParseDocument(source string, data []byte) (Policy, error) {
+	p := &documentParser{source: source}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Policy{}, p.syntaxError(err)
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return Policy{}, &DocumentError{Source: source, Message: "empty policy document"}
+	}
+
+	policy := p.parsePolicy(root.Content[0])
+	if len(p.errs) > 0 {
+		return Policy{}, errors.Join(p.errs...)
+	}
+	return policy, nil
+}
+
+type documentParser struct {
+	source string
+	errs   []error
+}
+
+func (p *documentParser) errorf(node *yaml.Node, format string, args ...any) {
+	p.errs = append(p.errs, &DocumentError{
+		Source:  p.source,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// syntaxError converts an error of the YAML parser, which looks like "yaml: line 3: ...", into a DocumentError.
+func (p *documentParser) syntaxError(err error) error {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	docErr := &DocumentError{Source: p.source, Message: msg}
+	if rest, ok := strings.CutPrefix(msg, "line "); ok {
+		if lineStr, detail, ok := strings.Cut(rest, ": "); ok {
+			if line, convErr := strconv.Atoi(lineStr); convErr == nil {
+				docErr.Line, docErr.Message = line, detail
+			}
+		}
+	}
+	return docErr
+}
+
+// mapping returns the alternating key and value nodes of a mapping node, in document order.
+func (p *documentParser) mapping(node *yaml.Node, what string) ([]*yaml.Node, bool) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		p.errorf(node, "%s must be a mapping", what)
+		return nil, false
+	}
+
+	seen := make(map[string]int, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if line, ok := seen[key.Value]; ok {
+			p.errorf(key, "duplicate key %q, first defined on line %d", key.Value, line)
+		}
+		seen[key.Value] = key.Line
+	}
+	return node.Content, true
+}
+
+func (p *documentParser) parsePolicy(node *yaml.Node) Policy {
+	var policy Policy
+	content, ok := p.mapping(node, "policy document")
+	if !ok {
+		return policy
+	}
+
+	hasRules := false
+	for i := 0; i < len(content); i += 2 {
+		key, value := content[i], content[i+1]
+		switch key.Value {
+		case "default":
+			policy.Default = p.parseDecision(value)
+		case "rules":
+			hasRules = true
+			policy.Rules = p.parseRules(value)
+		default:
+			p.errorf(key, "unknown field %q, expected default or rules", key.Value)
+		}
+	}
+	if !hasRules {
+		p.errorf(node, "missing field \"rules\"")
+	}
+	return policy
+}
+
+func (p *documentParser) parseRules(node *yaml.Node) []Rule {
+	if node.Kind != yaml.SequenceNode {
+		p.errorf(node, "rules must be a list")
+		return nil
+	}
+
+	rules := make([]Rule, 0, len(node.Content))
+	names := make(map[string]*yaml.Node)
+	for _, ruleNode := range node.Content {
+		rule, nameNode := p.parseRule(ruleNode)
+		if nameNode != nil {
+			if previous, ok := names[rule.Name]; ok {
+				p.errorf(nameNode, "duplicate rule name %q, first defined on line %d", rule.Name, previous.Line)
+			}
+			names[rule.Name] = nameNode
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (p *documentParser) parseRule(node *yaml.Node) (Rule, *yaml.Node) {
+	var rule Rule
+	content, ok := p.mapping(node, "rule")
+	if !ok {
+		return rule, nil
+	}
+
+	var nameNode *yaml.Node
+	hasWhen, hasDecision := false, false
+	for i := 0; i < len(content); i += 2 {
+		key, value := content[i], content[i+1]
+		switch key.Value {
+		case "name":
+			if value.Kind != yaml.ScalarNode || value.Value == "" {
+				p.errorf(value, "rule name must be a non-empty string")
+				continue
+			}
+			rule.Name = value.Value
+			nameNode = value
+		case "when":
+			hasWhen = true
+			rule.When = p.parseCondition(value)
+		case "decision":
+			hasDecision = true
+			rule.Decision = p.parseDecision(value)
+		default:
+			p.errorf(key, "unknown field %q, expected name, when or decision", key.Value)
+		}
+	}
+
+	if nameNode == nil {
+		p.errorf(node, "rule is missing field \"name\"")
+	}
+	if !hasWhen {
+		p.errorf(node, "rule is missing field \"when\"")
+	}
+	if !hasDecision {
+		p.errorf(node, "rule is missing field \"decision\"")
+	}
+	return rule, nameNode
+}
+
+func (p *documentParser) parseDecision(node *yaml.Node) Decision {
+	if node.Kind != yaml.ScalarNode {
+		p.errorf(node, "decision must be one of allow, challenge, review or deny")
+		return Allow
+	}
+	decision, err := ParseDecision(node.Value)
+	if err != nil {
+		p.errorf(node, "%v", err)
+	}
+	return decision
+}
+
+// boolConditions are the conditions that take true, or false for their negation.
+var boolConditions = map[string]func() Predicate{
+	"always":                     Always,
+	"has_risk_scores":            HasRiskScores,
+	"tor":                        Tor,
+	"icloud_private_relay":       ICloudPrivateRelay,
+	"automation_tool_detected":   AutomationToolDetected,
+	"known_bot_detected":         KnownBotDetected,
+	"time_zone_country_mismatch": TimeZoneCountryMismatch,
+}
+
+// scoreConditions are the conditions that take a risk score.
+var scoreConditions = map[string]func(friendlycaptcha.RiskScore) Predicate{
+	"overall_risk_at_least": OverallRiskAtLeast,
+	"network_risk_at_least": NetworkRiskAtLeast,
+	"browser_risk_at_least": BrowserRiskAtLeast,
+	"vpn_score_at_least":    VPNScoreAtLeast,
+	"proxy_score_at_least":  ProxyScoreAtLeast,
+}
+
+// stringListConditions are the conditions that take a list of strings.
+var stringListConditions = map[string]func(...string) Predicate{
+	"country_in":     CountryIn,
+	"as_type_in":     ASTypeIn,
+	"device_type_in": DeviceTypeIn,
+	"browser_in":     BrowserIn,
+}
+
+// never is returned for invalid conditions, it is never evaluated because the document is rejected.
+func never(*friendlycaptcha.RiskIntelligenceData) bool { return false }
+
+func (p *documentParser) parseCondition(node *yaml.Node) Predicate {
+	content, ok := p.mapping(node, "condition")
+	if !ok {
+		return never
+	}
+	if len(content) != 2 {
+		p.errorf(node, "condition must have exactly one key, use all or any to combine conditions")
+		return never
+	}
+
+	key, value := content[0], content[1]
+	name := key.Value
+	switch {
+	case name == "all" || name == "any":
+		if value.Kind != yaml.SequenceNode || len(value.Content) == 0 {
+			p.errorf(value, "%s must be a non-empty list of conditions", name)
+			return never
+		}
+		predicates := make([]Predicate, len(value.Content))
+		for i, child := range value.Content {
+			predicates[i] = p.parseCondition(child)
+		}
+		if name == "all" {
+			return And(predicates...)
+		}
+		return Or(predicates...)
+	case name == "not":
+		return Not(p.parseCondition(value))
+	case boolConditions[name] != nil:
+		var b bool
+		if value.Kind != yaml.ScalarNode || value.Decode(&b) != nil {
+			p.errorf(value, "%s must be true or false", name)
+			return never
+		}
+		if !b {
+			return Not(boolConditions[name]())
+		}
+		return boolConditions[name]()
+	case scoreConditions[name] != nil:
+		score, ok := p.parseRiskScore(value)
+		if !ok {
+			return never
+		}
+		return scoreConditions[name](score)
+	case stringListConditions[name] != nil:
+		values, ok := p.parseList(value, name)
+		if !ok {
+			return never
+		}
+		return stringListConditions[name](values...)
+	case name == "asn_in":
+		values, ok := p.parseList(value, name)
+		if !ok {
+			return never
+		}
+		numbers := make([]int, 0, len(values))
+		for i, v := range values {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				p.errorf(value.Content[i], "asn_in must be a list of numbers, got %q", v)
+				continue
+			}
+			numbers = append(numbers, n)
+		}
+		return ASNIn(numbers...)
+	case name == "expr":
+		if value.Kind != yaml.ScalarNode || value.Tag != "!!str" {
+			p.errorf(value, "expr must be a string")
+			return never
+		}
+		expr, err := CompileExpression(value.Value)
+		if err != nil {
+			p.errorf(value, "%v", err)
+			return never
+		}
+		if !expr.IsBool() {
+			p.errorf(value, "expr must evaluate to bool")
+			return never
+		}
+		return Expr(expr)
+	default:
+		p.errorf(key, "unknown condition %q", key.Value)
+		return never
+	}
+}
+
+var riskScoreNames = map[string]friendlycaptcha.RiskScore{
+	"very_low":  friendlycaptcha.RiskScoreVeryLow,
+	"low":       friendlycaptcha.RiskScoreLow,
+	"medium":    friendlycaptcha.RiskScoreMedium,
+	"high":      friendlycaptcha.RiskScoreHigh,
+	"very_high": friendlycaptcha.RiskScoreVeryHigh,
+}
+
+func (p *documentParser) parseRiskScore(node *yaml.Node) (friendlycaptcha.RiskScore, bool) {
+	if node.Kind == yaml.ScalarNode {
+		if score, ok := riskScoreNames[strings.ToLower(node.Value)]; ok {
+			return score, true
+		}
+		if n, err := strconv.Atoi(node.Value); err == nil && n >= 1 && n <= 5 {
+			return friendlycaptcha.RiskScore(n), true
+		}
+	}
+	p.errorf(node, "risk score must be a number from 1 to 5 or one of very_low, low, medium, high and very_high")
+	return friendlycaptcha.RiskScoreUnknown, false
+}
+
+func (p *documentParser) parseList(node *yaml.Node, name string) ([]string, bool) {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		p.errorf(node, "%s must be a non-empty list", name)
+		return nil, false
+	}
+	values := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode || item.Value == "" {
+			p.errorf(item, "%s must only contain non-empty values", name)
+			return nil, false
+		}
+		values = append(values, item.Value)
+	}
+	return values, true
+}
@@ -0,0 +1,183 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `
+default: allow
+rules:
+  - name: tor
+    when: {tor: true}
+    decision: deny
+  - name: high-risk
+    when: {overall_risk_at_least: high}
+    decision: review
+  - name: hosting-outside-eu
+    when:
+      all:
+        - as_type_in: [hosting]
+        - not: {country_in: [NL, FR]}
+        - asn_in: [24940]
+    decision: challenge
+  - name: mobile-or-bot
+    when:
+      any:
+        - device_type_in: [mobile]
+        - known_bot_detected: true
+    decision: deny
+  - name: has-no-tor
+    when: {tor: false}
+    decision: challenge
+`
+
+func TestParseDocument(t *testing.T) {
+	t.Parallel()
+
+	p, err := policy.ParseDocument("policy.yaml", []byte(testDocument))
+	require.NoError(t, err)
+	require.Len(t, p.Rules, 5)
+
+	result := p.Evaluate(decodeRiskIntelligence(t, fullRiskIntelligence))
+	assert.Equal(t, policy.Deny, result.Decision)
+	assert.Equal(t, []string{"tor", "high-risk", "hosting-outside-eu"}, result.MatchedNames())
+
+	result = p.Evaluate(decodeRiskIntelligence(t, minimalRiskIntelligence))
+	assert.Equal(t, policy.Challenge, result.Decision)
+	assert.Equal(t, []string{"has-no-tor"}, result.MatchedNames())
+}
+
+func TestParseDocumentJSON(t *testing.T) {
+	t.Parallel()
+
+	p, err := policy.ParseDocument("policy.json", []byte(`{
+		"default": "review",
+		"rules": [
+			{"name": "tor", "when": {"tor": true}, "decision": "deny"},
+			{"name": "low-risk", "when": {"not": {"overall_risk_at_least": 3}}, "decision": "allow"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, policy.Review, p.Default)
+	assert.Equal(t, policy.Deny, p.Evaluate(decodeRiskIntelligence(t, fullRiskIntelligence)).Decision)
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		document string
+		errors   []string
+	}{
+		{
+			name:     "syntax error",
+			document: "rules:\n\t- name: tor\n",
+			errors:   []string{"policy.yaml:2: found character that cannot start any token"},
+		},
+		{
+			name:     "empty",
+			document: "",
+			errors:   []string{"policy.yaml: empty policy document"},
+		},
+		{
+			name:     "not a mapping",
+			document: "- tor",
+			errors:   []string{"policy.yaml:1:1: policy document must be a mapping"},
+		},
+		{
+			name:     "missing rules",
+			document: "default: deny",
+			errors:   []string{`policy.yaml:1:1: missing field "rules"`},
+		},
+		{
+			name: "invalid fields",
+			document: `
+default: block
+rules:
+  - name: tor
+    when: {tors: true}
+    decision: deny
+    priority: 1
+  - when: {overall_risk_at_least: 6}
+    decision: review
+  - name: tor
+    when: {country_in: []}
+  - name: asn
+    when: {asn_in: [AS24940]}
+    decision: deny
+  - name: combined
+    when: {tor: true, known_bot_detected: true}
+    decision: deny
+  - name: nested
+    when:
+      any:
+        - not: {automation_tool_detected: yes please}
+    decision: deny
+  - name: twice
+    when: {tor: true}
+    when: {known_bot_detected: true}
+    decision: deny
+`,
+			errors: []string{
+				`policy.yaml:2:10: unknown decision "block", expected one of allow, challenge, review or deny`,
+				`policy.yaml:5:12: unknown condition "tors"`,
+				`policy.yaml:7:5: unknown field "priority", expected name, when or decision`,
+				`policy.yaml:8:35: risk score must be a number from 1 to 5 or one of very_low, low, medium, high and very_high`,
+				`policy.yaml:8:5: rule is missing field "name"`,
+				`policy.yaml:11:24: country_in must be a non-empty list`,
+				`policy.yaml:10:5: rule is missing field "decision"`,
+				`policy.yaml:10:11: duplicate rule name "tor", first defined on line 4`,
+				`policy.yaml:13:21: asn_in must be a list of numbers, got "AS24940"`,
+				`policy.yaml:16:11: condition must have exactly one key, use all or any to combine conditions`,
+				`policy.yaml:21:43: automation_tool_detected must be true or false`,
+				`policy.yaml:25:5: duplicate key "when", first defined on line 24`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := policy.ParseDocument("policy.yaml", []byte(tt.document))
+			require.Error(t, err)
+
+			var messages []string
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range joined.Unwrap() {
+					messages = append(messages, e.Error())
+				}
+			} else {
+				messages = []string{err.Error()}
+			}
+			assert.Equal(t, tt.errors, messages)
+
+			var docErr *policy.DocumentError
+			require.True(t, errors.As(err, &docErr))
+			assert.Equal(t, "policy.yaml", docErr.Source)
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testDocument), 0o600))
+
+	p, err := policy.LoadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, p.Rules, 5)
+
+	_, err = policy.LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,301 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// ExpressionVariable is the name of the variable that holds the risk intelligence data in expressions.
+const ExpressionVariable = "ri"
+
+// Expression is a compiled CEL expression (see https://cel.dev) over risk intelligence data, for conditions that can
+// not be expressed with the predicates in this package, e.g.:
+//
+//	ri.network.anonymization.tor || (ri.risk_scores.overall >= 4 && ri.client.device.type == "mobile")
+//
+// The data is available as the `ri` variable, with the fields named like in the JSON returned by the Friendly Captcha
+// API. Risk scores are integers from 1 to 5.
+//
+// Fields that are null, because the module that provides them is not enabled, are absent: evaluating an expression
+// that accesses them fails, unless presence is checked first with `has(ri.risk_scores)` or optional field selection
+// is used, e.g. `ri.?network.?anonymization.?tor.orValue(false)`.
+//
+// An Expression is safe for concurrent use.
+type Expression struct {
+	source     string
+	program    cel.Program
+	outputType *cel.Type
+}
+
+// CompileExpression parses and type-checks an expression. The expression must evaluate to a bool or a string.
+func CompileExpression(source string) (*Expression, error) {
+	env, err := expressionEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		messages := make([]string, 0, len(issues.Errors()))
+		for _, issue := range issues.Errors() {
+			messages = append(messages, fmt.Sprintf(
+				"%d:%d: %s", issue.Location.Line(), issue.Location.Column()+1, issue.Message,
+			))
+		}
+		return nil, fmt.Errorf("invalid expression %q: %s", source, strings.Join(messages, "; "))
+	}
+
+	outputType := ast.OutputType()
+	if !outputType.IsExactType(cel.BoolType) && !outputType.IsExactType(cel.StringType) {
+		return nil, fmt.Errorf("invalid expression %q: must evaluate to bool or string, not %s", source, outputType)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Expression{source: source, program: program, outputType: outputType}, nil
+}
+
+// MustCompileExpression is like CompileExpression but panics if the expression is invalid. It simplifies the
+// initialization of global variables holding expressions.
+func MustCompileExpression(source string) *Expression {
+	expr, err := CompileExpression(source)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// IsBool returns whether the expression evaluates to a bool, otherwise it evaluates to a string.
+func (e *Expression) IsBool() bool {
+	return e.outputType.IsExactType(cel.BoolType)
+}
+
+func (e *Expression) eval(data *friendlycaptcha.RiskIntelligenceData) (ref.Val, error) {
+	out, _, err := e.program.Eval(map[string]any{
+		ExpressionVariable: expressionValue(reflect.ValueOf(data).Elem()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("evaluating expression %q: %w", e.source, err)
+	}
+	return out, nil
+}
+
+// EvalBool evaluates an expression that evaluates to a bool.
+func (e *Expression) EvalBool(data friendlycaptcha.RiskIntelligenceData) (bool, error) {
+	if !e.IsBool() {
+		return false, fmt.Errorf("expression %q evaluates to %s, not bool", e.source, e.outputType)
+	}
+	out, err := e.eval(&data)
+	if err != nil {
+		return false, err
+	}
+	return out.Value().(bool), nil
+}
+
+// EvalString evaluates an expression that evaluates to a string.
+func (e *Expression) EvalString(data friendlycaptcha.RiskIntelligenceData) (string, error) {
+	if e.IsBool() {
+		return "", fmt.Errorf("expression %q evaluates to bool, not string", e.source)
+	}
+	out, err := e.eval(&data)
+	if err != nil {
+		return "", err
+	}
+	return out.Value().(string), nil
+}
+
+// Expr returns a Predicate that matches if the expression evaluates to true. It does not match if the evaluation
+// fails, e.g. because the expression accesses a field that is null.
+//
+// It panics if the expression does not evaluate to a bool.
+func Expr(e *Expression) Predicate {
+	if !e.IsBool() {
+		panic(fmt.Sprintf("policy: expression %q evaluates to %s, not bool", e.source, e.outputType))
+	}
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		out, err := e.eval(data)
+		return err == nil && out == types.True
+	}
+}
+
+var expressionEnv = sync.OnceValues(func() (*cel.Env, error) {
+	registry, err := types.NewRegistry()
+	if err != nil {
+		return nil, err
+	}
+	provider := &riskIntelligenceProvider{
+		Registry: registry,
+		objects:  make(map[string]map[string]*types.FieldType),
+	}
+	rootType := provider.register(reflect.TypeOf(friendlycaptcha.RiskIntelligenceData{}))
+
+	return cel.NewEnv(
+		cel.CustomTypeProvider(provider),
+		cel.CustomTypeAdapter(registry),
+		cel.OptionalTypes(),
+		cel.Variable(ExpressionVariable, rootType),
+	)
+})
+
+// riskIntelligenceProvider declares the risk intelligence structs as CEL object types. At runtime the values are maps,
+// see expressionValue, so no field getters are provided and fields are selected like map keys.
+//
+// It embeds the default registry, so that other types, like the optional type, can still be registered.
+type riskIntelligenceProvider struct {
+	*types.Registry
+	// objects contains the field types per object type name.
+	objects map[string]map[string]*types.FieldType
+}
+
+// register declares the object type for the struct type and all structs it contains.
+func (p *riskIntelligenceProvider) register(t reflect.Type) *cel.Type {
+	name := "friendlycaptcha." + t.Name()
+	if _, ok := p.objects[name]; ok {
+		return cel.ObjectType(name)
+	}
+	fields := make(map[string]*types.FieldType)
+	p.objects[name] = fields
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonName, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		fields[jsonName] = &types.FieldType{Type: p.fieldType(field.Type)}
+	}
+	return cel.ObjectType(name)
+}
+
+func (p *riskIntelligenceProvider) fieldType(t reflect.Type) *cel.Type {
+	if valueType, ok := nullValueType(t); ok {
+		return p.fieldType(valueType)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return cel.StringType
+	case reflect.Bool:
+		return cel.BoolType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cel.IntType
+	case reflect.Struct:
+		return p.register(t)
+	default:
+		panic(fmt.Sprintf("policy: unsupported risk intelligence field type %s", t))
+	}
+}
+
+// FindStructType implements types.Provider.
+func (p *riskIntelligenceProvider) FindStructType(structType string) (*types.Type, bool) {
+	if _, ok := p.objects[structType]; ok {
+		return types.NewTypeTypeWithParam(cel.ObjectType(structType)), true
+	}
+	return p.Registry.FindStructType(structType)
+}
+
+// FindStructFieldNames implements types.Provider.
+func (p *riskIntelligenceProvider) FindStructFieldNames(structType string) ([]string, bool) {
+	if fields, ok := p.objects[structType]; ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		return names, true
+	}
+	return p.Registry.FindStructFieldNames(structType)
+}
+
+// FindStructFieldType implements types.Provider.
+func (p *riskIntelligenceProvider) FindStructFieldType(structType, fieldName string) (*types.FieldType, bool) {
+	if fields, ok := p.objects[structType]; ok {
+		field, ok := fields[fieldName]
+		return field, ok
+	}
+	return p.Registry.FindStructFieldType(structType, fieldName)
+}
+
+// NewValue implements types.Provider, risk intelligence objects can not be created in expressions.
+func (p *riskIntelligenceProvider) NewValue(structType string, fields map[string]ref.Val) ref.Val {
+	if _, ok := p.objects[structType]; ok {
+		return types.NewErr("%s can not be created in expressions", structType)
+	}
+	return p.Registry.NewValue(structType, fields)
+}
+
+// expressionValue converts a risk intelligence struct to the value of an object type declared by
+// riskIntelligenceProvider. Null fields are left out.
+func expressionValue(v reflect.Value) any {
+	if _, ok := nullValueType(v.Type()); ok {
+		if !v.FieldByName("Valid").Bool() {
+			return nil
+		}
+		return expressionValue(v.FieldByName("V"))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Struct:
+		m := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			jsonName, ok := jsonFieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			if fieldValue := expressionValue(v.Field(i)); fieldValue != nil {
+				m[jsonName] = fieldValue
+			}
+		}
+		return m
+	default:
+		panic(fmt.Sprintf("policy: unsupported risk intelligence field type %s", v.Type()))
+	}
+}
+
+// jsonFieldName returns the name of the field in JSON, it returns false for fields that are not encoded.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return name, true
+	}
+}
+
+// nullValueType returns T if t is null.Value[T].
+func nullValueType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "github.com/guregu/null/v6" || !strings.HasPrefix(t.Name(), "Value[") {
+		return nil, false
+	}
+	field, ok := t.FieldByName("V")
+	if !ok {
+		return nil, false
+	}
+	return field.Type, true
+}
@@ -0,0 +1,159 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionEvalBool(t *testing.T) {
+	t.Parallel()
+
+	full := decodeRiskIntelligence(t, fullRiskIntelligence)
+	minimal := decodeRiskIntelligence(t, minimalRiskIntelligence)
+
+	tests := []struct {
+		expr       string
+		full       bool
+		minimal    bool
+		minimalErr bool
+	}{
+		{
+			expr:       `ri.network.anonymization.tor || (ri.risk_scores.overall >= 4 && ri.client.device.type == "mobile")`,
+			full:       true,
+			minimalErr: true,
+		},
+		{expr: `ri.risk_scores.overall >= 4 && ri.client.device.type == "mobile"`, minimalErr: true},
+		{expr: `ri.network.as.number == 24940 && ri.network.as.type == "hosting"`, full: true, minimalErr: true},
+		{expr: `ri.network.geolocation.country.iso2 in ["DE", "AT", "CH"]`, full: true, minimalErr: true},
+		{expr: `ri.client.automation.automation_tool.id.startsWith("pup")`, full: true, minimalErr: true},
+		{expr: `ri.client.header_user_agent.contains("Headless")`, full: true},
+		{expr: `ri.network.ip.startsWith("185.")`, full: true, minimal: true},
+		{expr: `has(ri.risk_scores)`, full: true},
+		{expr: `!has(ri.client.tls_signature)`, full: true, minimal: true},
+		{expr: `has(ri.risk_scores) && ri.risk_scores.browser > 3`},
+		{expr: `ri.?network.?anonymization.?tor.orValue(false)`, full: true},
+		{expr: `ri.?client.?device.?type.orValue("unknown") == "unknown"`, minimal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := policy.CompileExpression(tt.expr)
+			require.NoError(t, err)
+			assert.True(t, expr.IsBool())
+			assert.Equal(t, tt.expr, expr.String())
+
+			matches, err := expr.EvalBool(full)
+			require.NoError(t, err)
+			assert.Equal(t, tt.full, matches, "full")
+			assert.Equal(t, tt.full, policy.Expr(expr)(&full), "full predicate")
+
+			matches, err = expr.EvalBool(minimal)
+			if tt.minimalErr {
+				assert.ErrorContains(t, err, "no such key")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.minimal, matches, "minimal")
+			}
+			// A failed evaluation does not match.
+			assert.Equal(t, tt.minimal, policy.Expr(expr)(&minimal), "minimal predicate")
+		})
+	}
+}
+
+func TestExpressionEvalString(t *testing.T) {
+	t.Parallel()
+
+	expr, err := policy.CompileExpression(
+		`ri.?network.?anonymization.?tor.orValue(false) ? "deny" : ` +
+			`(has(ri.risk_scores) && ri.risk_scores.overall >= 3 ? "review" : "allow")`,
+	)
+	require.NoError(t, err)
+	assert.False(t, expr.IsBool())
+
+	outcome, err := expr.EvalString(decodeRiskIntelligence(t, fullRiskIntelligence))
+	require.NoError(t, err)
+	assert.Equal(t, "deny", outcome)
+
+	data := decodeRiskIntelligence(t, fullRiskIntelligence)
+	data.Network.Anonymization.V.Tor = false
+	outcome, err = expr.EvalString(data)
+	require.NoError(t, err)
+	assert.Equal(t, "review", outcome)
+
+	outcome, err = expr.EvalString(decodeRiskIntelligence(t, minimalRiskIntelligence))
+	require.NoError(t, err)
+	assert.Equal(t, "allow", outcome)
+
+	_, err = expr.EvalBool(data)
+	assert.ErrorContains(t, err, "not bool")
+	assert.Panics(t, func() { policy.Expr(expr) })
+}
+
+func TestCompileExpressionErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: `ri.network.anonymization.tors`, err: `1:25: undefined field 'tors'`},
+		{expr: `ri.risk_scores.overall >= "high"`, err: `1:24: found no matching overload for '_>=_'`},
+		{expr: `ri.risk_scores.overall`, err: `must evaluate to bool or string, not int`},
+		{expr: `ri.network.ip ==`, err: `1:17: Syntax error`},
+		{expr: `request.ip == ""`, err: `1:1: undeclared reference to 'request'`},
+	}
+
+	for _, tt := range tests {
+		_, err := policy.CompileExpression(tt.expr)
+		assert.ErrorContains(t, err, tt.err, tt.expr)
+	}
+
+	assert.Panics(t, func() { policy.MustCompileExpression("ri.") })
+}
+
+func TestParseDocumentExpr(t *testing.T) {
+	t.Parallel()
+
+	p, err := policy.ParseDocument("policy.yaml", []byte(`
+rules:
+  - name: tor-or-risky-mobile
+    when:
+      expr: ri.network.anonymization.tor || (ri.risk_scores.overall >= 4 && ri.client.device.type == "mobile")
+    decision: deny
+  - name: combined
+    when:
+      all:
+        - country_in: [DE]
+        - expr: 'ri.network.as.type == "hosting"'
+    decision: challenge
+`))
+	require.NoError(t, err)
+
+	result := p.Evaluate(decodeRiskIntelligence(t, fullRiskIntelligence))
+	assert.Equal(t, policy.Deny, result.Decision)
+	assert.Equal(t, []string{"tor-or-risky-mobile", "combined"}, result.MatchedNames())
+
+	result = p.Evaluate(decodeRiskIntelligence(t, minimalRiskIntelligence))
+	assert.Equal(t, policy.Allow, result.Decision)
+
+	_, err = policy.ParseDocument("policy.yaml", []byte(`
+rules:
+  - name: a
+    when: {expr: 'ri.network.tor'}
+    decision: deny
+  - name: b
+    when: {expr: 'ri.network.ip'}
+    decision: deny
+  - name: c
+    when: {expr: 42}
+    decision: deny
+`))
+	assert.ErrorContains(t, err, `policy.yaml:4:18: invalid expression "ri.network.tor": 1:11: undefined field 'tor'`)
+	assert.ErrorContains(t, err, `policy.yaml:7:18: expr must evaluate to bool`)
+	assert.ErrorContains(t, err, `policy.yaml:10:18: expr must be a string`)
+}
@@ -0,0 +1,152 @@
+// Package policy turns the risk intelligence data of a Friendly Captcha verification or retrieval into a decision.
+//
+// A Policy is a list of rules, each rule pairs a Predicate over RiskIntelligenceData with the Decision to take when
+// it matches. Predicates are safe to use with data from accounts where some modules are not enabled: a predicate
+// that depends on a field that is null does not match.
+//
+//	p := policy.Policy{
+//		Rules: []policy.Rule{
+//			{Name: "tor", When: policy.Tor(), Decision: policy.Deny},
+//			{Name: "high-risk", When: policy.OverallRiskAtLeast(friendlycaptcha.RiskScoreHigh), Decision: policy.Review},
+//		},
+//	}
+//	result := p.Evaluate(data)
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+)
+
+// Decision is what to do with a request. Decisions are ordered by severity, when multiple rules match the most severe
+// decision wins.
+type Decision int
+
+const (
+	// Allow lets the request through.
+	Allow Decision = iota
+	// Challenge asks the user for additional proof, e.g. a second factor or email confirmation.
+	Challenge
+	// Review lets the request through, but flags it for manual review.
+	Review
+	// Deny rejects the request.
+	Deny
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Challenge:
+		return "challenge"
+	case Review:
+		return "review"
+	case Deny:
+		return "deny"
+	default:
+		return fmt.Sprintf("Decision(%d)", int(d))
+	}
+}
+
+// ParseDecision parses the string representation of a Decision, e.g. "deny". It is case-insensitive.
+func ParseDecision(s string) (Decision, error) {
+	for d := Allow; d <= Deny; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return Allow, fmt.Errorf("unknown decision %q, expected one of allow, challenge, review or deny", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decision) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decision) UnmarshalText(text []byte) error {
+	decision, err := ParseDecision(string(text))
+	if err != nil {
+		return err
+	}
+	*d = decision
+	return nil
+}
+
+// Rule is a named Predicate and the Decision to take when it matches.
+type Rule struct {
+	// Name identifies the rule in a Result, e.g. "tor" or "hosting-provider".
+	Name string
+	// When is the condition under which the rule matches.
+	When Predicate
+	// Decision is the decision when the rule matches.
+	Decision Decision
+}
+
+// Result is the outcome of evaluating a Policy.
+type Result struct {
+	// Decision is the most severe decision of the matched rules, or the default decision of the policy if no rule
+	// matched.
+	Decision Decision
+	// Matched contains the rules that matched, in the order of the policy.
+	Matched []Rule
+}
+
+// MatchedNames returns the names of the rules that matched.
+func (r Result) MatchedNames() []string {
+	names := make([]string, len(r.Matched))
+	for i, rule := range r.Matched {
+		names[i] = rule.Name
+	}
+	return names
+}
+
+// Evaluator evaluates risk intelligence data, it is implemented by Policy.
+type Evaluator interface {
+	Evaluate(data friendlycaptcha.RiskIntelligenceData) Result
+}
+
+// Policy is an ordered list of rules.
+type Policy struct {
+	Rules []Rule
+	// Default is the decision when no rule matches. The zero value is Allow.
+	Default Decision
+}
+
+var _ Evaluator = Policy{}
+
+// Evaluate evaluates all rules against the data. Every rule is evaluated, so the Result lists all rules that matched
+// and not just the one that determined the decision.
+func (p Policy) Evaluate(data friendlycaptcha.RiskIntelligenceData) Result {
+	result := Result{Decision: p.Default}
+	for _, rule := range p.Rules {
+		if rule.When == nil || !rule.When(&data) {
+			continue
+		}
+		result.Matched = append(result.Matched, rule)
+		result.Decision = max(result.Decision, rule.Decision)
+	}
+	return result
+}
+
+// EvaluateVerifyResult evaluates the risk intelligence data in a VerifyResult. If the result contains no risk
+// intelligence data, e.g. because verification failed or risk intelligence is not enabled for your account, the rules
+// are evaluated against empty data, so only rules like `Not(HasRiskScores())` can match.
+func EvaluateVerifyResult(evaluator Evaluator, result friendlycaptcha.VerifyResult) Result {
+	var data friendlycaptcha.RiskIntelligenceData
+	if response := result.Response(); response.Data != nil {
+		data = response.Data.RiskIntelligence.V
+	}
+	return evaluator.Evaluate(data)
+}
+
+// EvaluateRetrieveResult is like EvaluateVerifyResult, for a RiskIntelligenceRetrieveResult.
+func EvaluateRetrieveResult(evaluator Evaluator, result friendlycaptcha.RiskIntelligenceRetrieveResult) Result {
+	var data friendlycaptcha.RiskIntelligenceData
+	if response := result.Response(); response.Data != nil {
+		data = response.Data.RiskIntelligence.V
+	}
+	return evaluator.Evaluate(data)
+}
@@ -0,0 +1,94 @@
+package policy_test
+
+import (
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy() policy.Policy {
+	return policy.Policy{
+		Rules: []policy.Rule{
+			{Name: "tor", When: policy.Tor(), Decision: policy.Deny},
+			{Name: "high-risk", When: policy.OverallRiskAtLeast(friendlycaptcha.RiskScoreHigh), Decision: policy.Review},
+			{Name: "hosting", When: policy.ASTypeIn("hosting"), Decision: policy.Challenge},
+			{Name: "known-bot", When: policy.KnownBotDetected(), Decision: policy.Deny},
+		},
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	t.Parallel()
+
+	p := testPolicy()
+
+	result := p.Evaluate(decodeRiskIntelligence(t, fullRiskIntelligence))
+	assert.Equal(t, policy.Deny, result.Decision)
+	assert.Equal(t, []string{"tor", "high-risk", "hosting"}, result.MatchedNames())
+
+	result = p.Evaluate(decodeRiskIntelligence(t, minimalRiskIntelligence))
+	assert.Equal(t, policy.Allow, result.Decision)
+	assert.Empty(t, result.Matched)
+
+	// The most severe decision wins, regardless of the order of the rules.
+	data := decodeRiskIntelligence(t, fullRiskIntelligence)
+	data.Network.Anonymization.V.Tor = false
+	result = p.Evaluate(data)
+	assert.Equal(t, policy.Review, result.Decision)
+	assert.Equal(t, []string{"high-risk", "hosting"}, result.MatchedNames())
+
+	p.Default = policy.Challenge
+	result = p.Evaluate(decodeRiskIntelligence(t, minimalRiskIntelligence))
+	assert.Equal(t, policy.Challenge, result.Decision)
+}
+
+func TestPolicyEvaluateResults(t *testing.T) {
+	t.Parallel()
+
+	p := testPolicy()
+
+	result := policy.EvaluateRetrieveResult(p, friendlycaptchatest.ValidRetrieveResult(
+		decodeRiskIntelligence(t, fullRiskIntelligence),
+	))
+	assert.Equal(t, policy.Deny, result.Decision)
+
+	result = policy.EvaluateRetrieveResult(p, friendlycaptchatest.FailedRetrieveResult())
+	assert.Equal(t, policy.Allow, result.Decision)
+
+	// No risk intelligence in the verify result.
+	result = policy.EvaluateVerifyResult(p, friendlycaptchatest.AcceptedVerifyResult())
+	assert.Equal(t, policy.Allow, result.Decision)
+
+	result = policy.EvaluateVerifyResult(p, friendlycaptchatest.FailedVerifyResult(false))
+	assert.Equal(t, policy.Allow, result.Decision)
+}
+
+func TestDecision(t *testing.T) {
+	t.Parallel()
+
+	for _, d := range []policy.Decision{policy.Allow, policy.Challenge, policy.Review, policy.Deny} {
+		parsed, err := policy.ParseDecision(d.String())
+		require.NoError(t, err)
+		assert.Equal(t, d, parsed)
+	}
+
+	parsed, err := policy.ParseDecision("DENY")
+	require.NoError(t, err)
+	assert.Equal(t, policy.Deny, parsed)
+
+	_, err = policy.ParseDecision("block")
+	assert.Error(t, err)
+
+	text, err := policy.Review.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "review", string(text))
+
+	var d policy.Decision
+	require.NoError(t, d.UnmarshalText([]byte("challenge")))
+	assert.Equal(t, policy.Challenge, d)
+	assert.Error(t, d.UnmarshalText([]byte("")))
+}
@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+)
+
+// Predicate is a condition over risk intelligence data. Predicates must not modify the data.
+type Predicate func(data *friendlycaptcha.RiskIntelligenceData) bool
+
+// And matches if all predicates match. It matches if no predicates are given.
+func And(predicates ...Predicate) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		for _, p := range predicates {
+			if !p(data) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches if any of the predicates matches. It does not match if no predicates are given.
+func Or(predicates ...Predicate) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		for _, p := range predicates {
+			if p(data) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches if the predicate does not match.
+//
+// Note that predicates over a field that is null do not match, so their negation does match. Combine with e.g.
+// HasRiskScores if that is not what you want.
+func Not(predicate Predicate) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return !predicate(data)
+	}
+}
+
+// Always matches any data, it is useful as the condition of a catch-all rule.
+func Always() Predicate {
+	return func(*friendlycaptcha.RiskIntelligenceData) bool {
+		return true
+	}
+}
+
+// HasRiskScores matches if the data contains risk scores, i.e. the Risk Scores module is enabled.
+func HasRiskScores() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.RiskScores.Valid
+	}
+}
+
+// OverallRiskAtLeast matches if the overall risk score is at least the given score.
+func OverallRiskAtLeast(score friendlycaptcha.RiskScore) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.RiskScores.Valid && scoreAtLeast(data.RiskScores.V.Overall, score)
+	}
+}
+
+// NetworkRiskAtLeast matches if the network risk score is at least the given score.
+func NetworkRiskAtLeast(score friendlycaptcha.RiskScore) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.RiskScores.Valid && scoreAtLeast(data.RiskScores.V.Network, score)
+	}
+}
+
+// BrowserRiskAtLeast matches if the browser risk score is at least the given score.
+func BrowserRiskAtLeast(score friendlycaptcha.RiskScore) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.RiskScores.Valid && scoreAtLeast(data.RiskScores.V.Browser, score)
+	}
+}
+
+// scoreAtLeast compares two risk scores, an unknown score never matches.
+func scoreAtLeast(actual, threshold friendlycaptcha.RiskScore) bool {
+	return actual != friendlycaptcha.RiskScoreUnknown && actual >= threshold
+}
+
+// Tor matches if the IP address is a Tor exit node.
+func Tor() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.Anonymization.Valid && data.Network.Anonymization.V.Tor
+	}
+}
+
+// ICloudPrivateRelay matches if the IP address belongs to iCloud Private Relay.
+func ICloudPrivateRelay() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.Anonymization.Valid && data.Network.Anonymization.V.ICloudPrivateRelay
+	}
+}
+
+// VPNScoreAtLeast matches if the likelihood that the IP address belongs to a VPN is at least the given score.
+func VPNScoreAtLeast(score friendlycaptcha.RiskScore) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.Anonymization.Valid && scoreAtLeast(data.Network.Anonymization.V.VPNScore, score)
+	}
+}
+
+// ProxyScoreAtLeast matches if the likelihood that the IP address belongs to a proxy is at least the given score.
+func ProxyScoreAtLeast(score friendlycaptcha.RiskScore) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.Anonymization.Valid && scoreAtLeast(data.Network.Anonymization.V.ProxyScore, score)
+	}
+}
+
+// CountryIn matches if the IP address is geolocated in one of the given countries, as two-letter ISO 3166-1 alpha-2
+// codes (e.g. "DE"). The comparison is case-insensitive.
+func CountryIn(countries ...string) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.Geolocation.Valid && containsFold(countries, data.Network.Geolocation.V.Country.ISO2)
+	}
+}
+
+// ASNIn matches if the IP address belongs to one of the given autonomous system numbers.
+func ASNIn(numbers ...int) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.AS.Valid && slices.Contains(numbers, data.Network.AS.V.Number)
+	}
+}
+
+// ASTypeIn matches if the autonomous system of the IP address has one of the given types, e.g. "hosting". The
+// comparison is case-insensitive.
+func ASTypeIn(types ...string) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Network.AS.Valid && containsFold(types, data.Network.AS.V.Type)
+	}
+}
+
+// AutomationToolDetected matches if an automation tool such as Puppeteer or Selenium was detected.
+func AutomationToolDetected() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Client.Automation.Valid && data.Client.Automation.V.AutomationTool.Detected
+	}
+}
+
+// KnownBotDetected matches if a known bot such as Googlebot was detected.
+func KnownBotDetected() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Client.Automation.Valid && data.Client.Automation.V.KnownBot.Detected
+	}
+}
+
+// DeviceTypeIn matches if the device has one of the given types, e.g. "mobile". The comparison is case-insensitive.
+func DeviceTypeIn(types ...string) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Client.Device.Valid && containsFold(types, data.Client.Device.V.Type)
+	}
+}
+
+// BrowserIn matches if the browser has one of the given IDs, e.g. "firefox". The comparison is case-insensitive.
+func BrowserIn(ids ...string) Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		return data.Client.Browser.Valid && containsFold(ids, data.Client.Browser.V.ID)
+	}
+}
+
+// TimeZoneCountryMismatch matches if the country of the browser's time zone differs from the country the IP address is
+// geolocated in, which is common for users of VPNs and proxies. It does not match if either country is unknown.
+func TimeZoneCountryMismatch() Predicate {
+	return func(data *friendlycaptcha.RiskIntelligenceData) bool {
+		if !data.Client.TimeZone.Valid || !data.Network.Geolocation.Valid {
+			return false
+		}
+		timeZoneCountry := data.Client.TimeZone.V.CountryISO2
+		ipCountry := data.Network.Geolocation.V.Country.ISO2
+		if timeZoneCountry == "" || timeZoneCountry == "XU" || ipCountry == "" {
+			return false
+		}
+		return !strings.EqualFold(timeZoneCountry, ipCountry)
+	}
+}
+
+// containsFold returns whether values contains s, ignoring case. The empty string is never contained.
+func containsFold(values []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, s)
+	})
+}
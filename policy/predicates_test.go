@@ -0,0 +1,123 @@
+package policy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fullRiskIntelligence has every module enabled.
+const fullRiskIntelligence = `{
+	"risk_scores": {"overall": 4, "network": 5, "browser": 2},
+	"network": {
+		"ip": "185.220.101.1",
+		"as": {"number": 24940, "name": "HETZNER-AS", "company": "Hetzner Online GmbH", "country": "DE", "type": "hosting"},
+		"geolocation": {"country": {"iso2": "DE", "iso3": "DEU", "name": "Germany"}, "city": "Falkenstein"},
+		"abuse_contact": {"email": "abuse@hetzner.com"},
+		"anonymization": {"vpn_score": 3, "proxy_score": 1, "tor": true, "icloud_private_relay": false}
+	},
+	"client": {
+		"header_user_agent": "Mozilla/5.0 (X11; Linux x86_64) HeadlessChrome/120.0.0.0",
+		"time_zone": {"name": "America/New_York", "country_iso2": "US"},
+		"browser": {"id": "chrome", "name": "Chrome", "version": "120.0"},
+		"device": {"type": "desktop"},
+		"automation": {
+			"automation_tool": {"detected": true, "id": "puppeteer", "name": "Puppeteer"},
+			"known_bot": {"detected": false}
+		}
+	}
+}`
+
+// minimalRiskIntelligence has no modules enabled.
+const minimalRiskIntelligence = `{
+	"risk_scores": null,
+	"network": {"ip": "185.220.101.1", "as": null, "geolocation": null, "abuse_contact": null, "anonymization": null},
+	"client": {
+		"header_user_agent": "Mozilla/5.0",
+		"time_zone": null,
+		"browser": null,
+		"browser_engine": null,
+		"device": null,
+		"os": null,
+		"tls_signature": null,
+		"automation": null
+	}
+}`
+
+func decodeRiskIntelligence(t *testing.T, s string) friendlycaptcha.RiskIntelligenceData {
+	t.Helper()
+
+	var data friendlycaptcha.RiskIntelligenceData
+	require.NoError(t, json.Unmarshal([]byte(s), &data))
+	return data
+}
+
+func TestPredicates(t *testing.T) {
+	t.Parallel()
+
+	full := decodeRiskIntelligence(t, fullRiskIntelligence)
+	minimal := decodeRiskIntelligence(t, minimalRiskIntelligence)
+
+	tests := []struct {
+		name      string
+		predicate policy.Predicate
+		full      bool
+		minimal   bool
+	}{
+		{name: "has risk scores", predicate: policy.HasRiskScores(), full: true},
+		{name: "overall >= high", predicate: policy.OverallRiskAtLeast(friendlycaptcha.RiskScoreHigh), full: true},
+		{name: "overall >= very high", predicate: policy.OverallRiskAtLeast(friendlycaptcha.RiskScoreVeryHigh)},
+		{name: "network >= very high", predicate: policy.NetworkRiskAtLeast(friendlycaptcha.RiskScoreVeryHigh), full: true},
+		{name: "browser >= medium", predicate: policy.BrowserRiskAtLeast(friendlycaptcha.RiskScoreMedium)},
+		{name: "tor", predicate: policy.Tor(), full: true},
+		{name: "icloud private relay", predicate: policy.ICloudPrivateRelay()},
+		{name: "vpn >= medium", predicate: policy.VPNScoreAtLeast(friendlycaptcha.RiskScoreMedium), full: true},
+		{name: "proxy >= low", predicate: policy.ProxyScoreAtLeast(friendlycaptcha.RiskScoreLow)},
+		{name: "country in", predicate: policy.CountryIn("nl", "de"), full: true},
+		{name: "country not in", predicate: policy.CountryIn("NL", "BE")},
+		{name: "asn in", predicate: policy.ASNIn(16509, 24940), full: true},
+		{name: "as type hosting", predicate: policy.ASTypeIn("Hosting"), full: true},
+		{name: "automation tool", predicate: policy.AutomationToolDetected(), full: true},
+		{name: "known bot", predicate: policy.KnownBotDetected()},
+		{name: "device type", predicate: policy.DeviceTypeIn("desktop", "tablet"), full: true},
+		{name: "browser", predicate: policy.BrowserIn("firefox")},
+		{name: "time zone country mismatch", predicate: policy.TimeZoneCountryMismatch(), full: true},
+		{name: "always", predicate: policy.Always(), full: true, minimal: true},
+		{name: "not", predicate: policy.Not(policy.Tor()), minimal: true},
+		{
+			name:      "and",
+			predicate: policy.And(policy.Tor(), policy.OverallRiskAtLeast(friendlycaptcha.RiskScoreHigh)),
+			full:      true,
+		},
+		{name: "and without predicates", predicate: policy.And(), full: true, minimal: true},
+		{name: "or", predicate: policy.Or(policy.KnownBotDetected(), policy.CountryIn("DE")), full: true},
+		{name: "or without predicates", predicate: policy.Or()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.full, tt.predicate(&full), "full")
+			assert.Equal(t, tt.minimal, tt.predicate(&minimal), "minimal")
+
+			var zero friendlycaptcha.RiskIntelligenceData
+			assert.Equal(t, tt.minimal, tt.predicate(&zero), "zero value")
+		})
+	}
+}
+
+func TestTimeZoneCountryMismatchUnknownCountry(t *testing.T) {
+	t.Parallel()
+
+	data := decodeRiskIntelligence(t, fullRiskIntelligence)
+	data.Client.TimeZone.V.CountryISO2 = "XU"
+	assert.False(t, policy.TimeZoneCountryMismatch()(&data))
+
+	data.Client.TimeZone.V.CountryISO2 = "de"
+	assert.False(t, policy.TimeZoneCountryMismatch()(&data))
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+)
+
+// DefaultPollInterval is how often a Watcher checks its file for changes by default.
+const DefaultPollInterval = 2 * time.Second
+
+// A WatchOption is a function that can be passed to WatchFile to configure the Watcher.
+type WatchOption func(*Watcher)
+
+// WithPollInterval sets how often the file is checked for changes. Intervals that are not positive are ignored.
+//
+// This defaults to DefaultPollInterval.
+func WithPollInterval(interval time.Duration) WatchOption {
+	return func(w *Watcher) {
+		if interval > 0 {
+			w.interval = interval
+		}
+	}
+}
+
+// WithReloadHook sets a function that is called after the file changed and was reloaded. The error is nil if the new
+// policy is in use, otherwise it is the reason the last good policy was kept.
+func WithReloadHook(hook func(err error)) WatchOption {
+	return func(w *Watcher) {
+		w.hook = hook
+	}
+}
+
+// Watcher evaluates the policy in a policy document file, and reloads it when the file changes. It is safe for
+// concurrent use, evaluation does not take any locks.
+//
+// If the changed file can not be read or is invalid, the last good policy stays in use and the error is available
+// from Err until the file is fixed. Replace the file atomically, e.g. by writing a temporary file and renaming it, to
+// avoid reloading a partially written file.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	hook     func(err error)
+
+	current atomic.Pointer[Policy]
+
+	// mu serializes reloads.
+	mu sync.Mutex
+	// content is the content of the file at the last reload, whether it was valid or not.
+	content []byte
+	err     error
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+var _ Evaluator = (*Watcher)(nil)
+
+// WatchFile loads the policy document at the given path, see ParseDocument, and starts watching it for changes. It
+// returns an error if the initial policy can not be loaded.
+//
+// Call Close to stop watching.
+func WatchFile(path string, opts ...WatchOption) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		interval: DefaultPollInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParseDocument(path, content)
+	if err != nil {
+		return nil, err
+	}
+	w.content = content
+	w.current.Store(&policy)
+
+	go w.run()
+	return w, nil
+}
+
+// Evaluate evaluates the current policy.
+func (w *Watcher) Evaluate(data friendlycaptcha.RiskIntelligenceData) Result {
+	return w.current.Load().Evaluate(data)
+}
+
+// Policy returns the current policy.
+func (w *Watcher) Policy() Policy {
+	return *w.current.Load()
+}
+
+// Err returns the reason the last change to the file could not be loaded, or nil if the current policy reflects the
+// file.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Reload checks the file for changes right away, instead of waiting for the next poll. It returns the same error as
+// Err afterwards.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	changed, err := w.reloadLocked()
+	w.mu.Unlock()
+
+	if changed && w.hook != nil {
+		w.hook(err)
+	}
+	return err
+}
+
+// reloadLocked reloads the file if its content changed since the last reload.
+func (w *Watcher) reloadLocked() (changed bool, err error) {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		// Forget the content, so the file is reloaded once it is readable again even if it did not change.
+		changed = w.content != nil
+		w.content = nil
+		w.err = err
+		return changed, err
+	}
+	if w.content != nil && bytes.Equal(content, w.content) {
+		return false, w.err
+	}
+
+	w.content = content
+	policy, err := ParseDocument(w.path, content)
+	if err != nil {
+		w.err = err
+		return true, err
+	}
+	w.current.Store(&policy)
+	w.err = nil
+	return true, nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			_ = w.Reload()
+		}
+	}
+}
+
+// Close stops watching the file. The Watcher keeps evaluating the last policy.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
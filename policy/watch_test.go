@@ -0,0 +1,119 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/friendlycaptcha/friendly-captcha-go/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	denyTorDocument = `
+rules:
+  - {name: tor, when: {tor: true}, decision: deny}
+`
+	reviewTorDocument = `
+rules:
+  - {name: tor, when: {tor: true}, decision: review}
+`
+)
+
+// writeFile replaces the file atomically, so the watcher never sees a partially written file.
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestWatchFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writeFile(t, path, denyTorDocument)
+
+	reloads := make(chan error, 10)
+	watcher, err := policy.WatchFile(path,
+		policy.WithPollInterval(10*time.Millisecond),
+		policy.WithReloadHook(func(err error) { reloads <- err }),
+	)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	data := decodeRiskIntelligence(t, fullRiskIntelligence)
+	assert.Equal(t, policy.Deny, watcher.Evaluate(data).Decision)
+
+	writeFile(t, path, reviewTorDocument)
+	require.NoError(t, <-reloads)
+	assert.Equal(t, policy.Review, watcher.Evaluate(data).Decision)
+	assert.NoError(t, watcher.Err())
+
+	// A bad reload keeps the last good policy.
+	writeFile(t, path, "rules:\n  - {name: tor, when: {tor: true}, decision: block}\n")
+	err = <-reloads
+	assert.ErrorContains(t, err, `policy.yaml:2:46: unknown decision "block"`)
+	assert.Equal(t, err, watcher.Err())
+	assert.Equal(t, policy.Review, watcher.Evaluate(data).Decision)
+
+	writeFile(t, path, denyTorDocument)
+	require.NoError(t, <-reloads)
+	assert.Equal(t, policy.Deny, watcher.Evaluate(data).Decision)
+	assert.NoError(t, watcher.Err())
+}
+
+func TestWatcherReload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writeFile(t, path, denyTorDocument)
+
+	// Only reload explicitly.
+	watcher, err := policy.WatchFile(path, policy.WithPollInterval(time.Hour))
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, watcher.Reload())
+
+	// The file is removed, e.g. while it is being replaced.
+	require.NoError(t, os.Remove(path))
+	assert.ErrorIs(t, watcher.Reload(), os.ErrNotExist)
+	assert.ErrorIs(t, watcher.Err(), os.ErrNotExist)
+	assert.Len(t, watcher.Policy().Rules, 1)
+
+	// The same content is loaded again once the file is back.
+	writeFile(t, path, denyTorDocument)
+	require.NoError(t, watcher.Reload())
+	assert.NoError(t, watcher.Err())
+}
+
+func TestWatchFileInvalidPollInterval(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writeFile(t, path, denyTorDocument)
+
+	// The default is used instead, starting the watcher must not panic.
+	for _, interval := range []time.Duration{0, -time.Second} {
+		watcher, err := policy.WatchFile(path, policy.WithPollInterval(interval))
+		require.NoError(t, err)
+		require.NoError(t, watcher.Reload())
+		watcher.Close()
+	}
+}
+
+func TestWatchFileInvalid(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writeFile(t, path, "rules: {}")
+
+	_, err := policy.WatchFile(path)
+	assert.ErrorContains(t, err, "rules must be a list")
+
+	_, err = policy.WatchFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
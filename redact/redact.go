@@ -0,0 +1,282 @@
+// Package redact removes personal data from risk intelligence before it is logged or stored.
+//
+// The IP address, the abuse contact of the network, the User-Agent header and the TLS signatures in the risk
+// intelligence data can be personal data, e.g. under the GDPR. A Redactor truncates, pseudonymizes or drops them
+// according to a Profile. It works on the decoded RiskIntelligenceData as well as on the raw JSON, and the redacted
+// JSON can still be decoded into RiskIntelligenceData:
+//
+//	redactor, err := redact.New(redact.PseudonymizedProfile(key))
+//	if err != nil {
+//		return err
+//	}
+//	data := redactor.Data(result.Response().Data.RiskIntelligence.V)
+//
+// To redact the events archived by an EventSink, wrap the sink with Sink.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/guregu/null/v6"
+)
+
+// IPMode is how the IP address is redacted.
+type IPMode int
+
+const (
+	// IPKeep keeps the IP address.
+	IPKeep IPMode = iota
+	// IPTruncate zeroes the host part of the IP address: IPv4 addresses are truncated to their /24 network, e.g.
+	// "88.64.4.0", IPv6 addresses to their /48 network, e.g. "2001:db8:1::".
+	IPTruncate
+	// IPPseudonymize replaces the IP address with its keyed HMAC, see Redactor.PseudonymizeIP.
+	IPPseudonymize
+	// IPDrop replaces the IP address with an empty string.
+	IPDrop
+)
+
+// UserAgentMode is how the User-Agent header is redacted.
+type UserAgentMode int
+
+const (
+	// UserAgentKeep keeps the User-Agent header.
+	UserAgentKeep UserAgentMode = iota
+	// UserAgentFamily replaces the User-Agent header with the browser family, e.g. "Firefox", see UserAgentFamilyOf.
+	UserAgentFamily
+	// UserAgentDrop replaces the User-Agent header with an empty string.
+	UserAgentDrop
+)
+
+// Profile configures what a Redactor redacts. The zero value keeps all data.
+type Profile struct {
+	// IP is how `network.ip` is redacted.
+	IP IPMode
+	// UserAgent is how `client.header_user_agent` is redacted.
+	UserAgent UserAgentMode
+	// DropAbuseContact sets `network.abuse_contact` to null.
+	DropAbuseContact bool
+	// PseudonymizeTLSSignatures replaces the JA3, JA3N and JA4 values in `client.tls_signature` with their keyed HMAC.
+	PseudonymizeTLSSignatures bool
+	// Key is the secret key for pseudonymization, it is required if IP is IPPseudonymize or PseudonymizeTLSSignatures
+	// is set. Use a random key of at least 32 bytes, and keep it secret: with the key, pseudonyms of known values can
+	// be computed.
+	Key []byte
+}
+
+// DefaultProfile returns a profile that truncates the IP address, drops the abuse contact and generalizes the
+// User-Agent header to the browser family. It doesn't require a key.
+func DefaultProfile() Profile {
+	return Profile{
+		IP:               IPTruncate,
+		UserAgent:        UserAgentFamily,
+		DropAbuseContact: true,
+	}
+}
+
+// PseudonymizedProfile returns a profile that pseudonymizes the IP address and TLS signatures with the given key,
+// drops the abuse contact and generalizes the User-Agent header to the browser family. Unlike truncation,
+// pseudonymization still allows correlating requests from the same IP address or TLS stack.
+func PseudonymizedProfile(key []byte) Profile {
+	return Profile{
+		IP:                        IPPseudonymize,
+		UserAgent:                 UserAgentFamily,
+		DropAbuseContact:          true,
+		PseudonymizeTLSSignatures: true,
+		Key:                       key,
+	}
+}
+
+// minKeySize is the minimum size of a pseudonymization key in bytes.
+const minKeySize = 16
+
+// Redactor redacts risk intelligence according to a Profile. A Redactor is safe for concurrent use.
+type Redactor struct {
+	profile Profile
+}
+
+// New returns a Redactor for the profile. It returns an error if the profile requires a key and the key is missing or
+// shorter than 16 bytes.
+func New(profile Profile) (*Redactor, error) {
+	switch profile.IP {
+	case IPKeep, IPTruncate, IPPseudonymize, IPDrop:
+	default:
+		return nil, fmt.Errorf("redact: unknown IP mode %d", profile.IP)
+	}
+	switch profile.UserAgent {
+	case UserAgentKeep, UserAgentFamily, UserAgentDrop:
+	default:
+		return nil, fmt.Errorf("redact: unknown User-Agent mode %d", profile.UserAgent)
+	}
+	if profile.IP == IPPseudonymize || profile.PseudonymizeTLSSignatures {
+		if len(profile.Key) < minKeySize {
+			return nil, fmt.Errorf("redact: pseudonymization requires a key of at least %d bytes", minKeySize)
+		}
+	}
+	profile.Key = bytes.Clone(profile.Key)
+	return &Redactor{profile: profile}, nil
+}
+
+// MustNew is like New but panics if the profile is invalid.
+func MustNew(profile Profile) *Redactor {
+	r, err := New(profile)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Data returns a redacted copy of the risk intelligence data.
+func (r *Redactor) Data(data friendlycaptcha.RiskIntelligenceData) friendlycaptcha.RiskIntelligenceData {
+	data.Network.IP = r.ip(data.Network.IP)
+	if r.profile.DropAbuseContact {
+		data.Network.AbuseContact = null.Value[friendlycaptcha.NetworkAbuseContactData]{}
+	}
+	data.Client.HeaderUserAgent = r.userAgent(data.Client.HeaderUserAgent)
+	if r.profile.PseudonymizeTLSSignatures && data.Client.TLSSignature.Valid {
+		signature := &data.Client.TLSSignature.V
+		signature.JA3 = r.pseudonymize("ja3", signature.JA3)
+		signature.JA3N = r.pseudonymize("ja3n", signature.JA3N)
+		signature.JA4 = r.pseudonymize("ja4", signature.JA4)
+	}
+	return data
+}
+
+// Raw returns a redacted copy of raw risk intelligence JSON, e.g. `RiskIntelligenceRaw`. Fields that are not modeled
+// in RiskIntelligenceData are kept. The JSON `null` is returned as-is.
+func (r *Redactor) Raw(raw json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		return raw, nil
+	}
+
+	var data map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// Keep numbers as they are, instead of converting them to float64.
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("redact: decoding risk intelligence: %w", err)
+	}
+	if data == nil {
+		return nil, errors.New("redact: risk intelligence must be a JSON object")
+	}
+
+	if network, ok := data["network"].(map[string]any); ok {
+		if ip, ok := network["ip"].(string); ok {
+			network["ip"] = r.ip(ip)
+		}
+		if _, ok := network["abuse_contact"]; ok && r.profile.DropAbuseContact {
+			network["abuse_contact"] = nil
+		}
+	}
+	if client, ok := data["client"].(map[string]any); ok {
+		if userAgent, ok := client["header_user_agent"].(string); ok {
+			client["header_user_agent"] = r.userAgent(userAgent)
+		}
+		if signature, ok := client["tls_signature"].(map[string]any); ok && r.profile.PseudonymizeTLSSignatures {
+			for _, field := range []string{"ja3", "ja3n", "ja4"} {
+				if value, ok := signature[field].(string); ok {
+					signature[field] = r.pseudonymize(field, value)
+				}
+			}
+		}
+	}
+
+	redacted, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("redact: encoding risk intelligence: %w", err)
+	}
+	return redacted, nil
+}
+
+// RawValue is like Raw for a nullable value, e.g. `VerifyResponseData.RiskIntelligenceRaw`.
+func (r *Redactor) RawValue(raw null.Value[json.RawMessage]) (null.Value[json.RawMessage], error) {
+	if !raw.Valid {
+		return raw, nil
+	}
+	redacted, err := r.Raw(raw.V)
+	if err != nil {
+		return null.Value[json.RawMessage]{}, err
+	}
+	return null.ValueFrom(redacted), nil
+}
+
+// PseudonymizeIP returns the pseudonym of an IP address, e.g. to look up the archived records of an IP address. It
+// returns an empty string if the profile has no key.
+func (r *Redactor) PseudonymizeIP(ip string) string {
+	if len(r.profile.Key) == 0 {
+		return ""
+	}
+	return r.pseudonymize("ip", ip)
+}
+
+func (r *Redactor) ip(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	switch r.profile.IP {
+	case IPTruncate:
+		return truncateIP(ip)
+	case IPPseudonymize:
+		return r.pseudonymize("ip", ip)
+	case IPDrop:
+		return ""
+	default:
+		return ip
+	}
+}
+
+func (r *Redactor) userAgent(userAgent string) string {
+	switch r.profile.UserAgent {
+	case UserAgentFamily:
+		return UserAgentFamilyOf(userAgent)
+	case UserAgentDrop:
+		return ""
+	default:
+		return userAgent
+	}
+}
+
+// pseudonymize returns the hex encoded HMAC-SHA256 of the value, truncated to 128 bits. The kind of the value is
+// part of the message, so the pseudonyms of different fields can't be correlated. Empty values are kept.
+func (r *Redactor) pseudonymize(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	if kind == "ip" {
+		// Different notations of the same address get the same pseudonym.
+		if addr, err := netip.ParseAddr(value); err == nil {
+			value = addr.Unmap().String()
+		}
+	}
+	mac := hmac.New(sha256.New, r.profile.Key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// truncateIP returns the /24 network of an IPv4 address or the /48 network of an IPv6 address. Values that are not
+// IP addresses are dropped.
+func truncateIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
@@ -0,0 +1,231 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/redact"
+	"github.com/guregu/null/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+const riskIntelligence = `{
+	"risk_scores": {"overall": 4, "network": 5, "browser": 2},
+	"network": {
+		"ip": "88.64.4.22",
+		"as": {"number": 3209, "name": "VODANET", "type": "isp"},
+		"geolocation": {"country": {"iso2": "DE"}, "city": "Eschborn"},
+		"abuse_contact": {"name": "Vodafone", "email": "abuse.de@vodafone.com", "phone": "+49 6196 52352105"},
+		"anonymization": {"vpn_score": 1, "proxy_score": 1, "tor": false, "icloud_private_relay": false}
+	},
+	"client": {
+		"header_user_agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:146.0) Gecko/20100101 Firefox/146.0",
+		"browser": {"id": "firefox", "name": "Firefox", "version": "146.0"},
+		"tls_signature": {
+			"ja3": "d87a30a5782a73a83c1544bb06332780",
+			"ja3n": "28ecc2d2875b345cecbb632b12d8c1e0",
+			"ja4": "t13d1516h2_8daaf6152771_02713d6af862"
+		},
+		"future_field": {"nested": 12345678901234567890}
+	}
+}`
+
+func decode(t *testing.T, raw []byte) friendlycaptcha.RiskIntelligenceData {
+	t.Helper()
+	var data friendlycaptcha.RiskIntelligenceData
+	require.NoError(t, json.Unmarshal(raw, &data))
+	return data
+}
+
+func TestRedactorDefaultProfile(t *testing.T) {
+	t.Parallel()
+
+	redactor := redact.MustNew(redact.DefaultProfile())
+	original := decode(t, []byte(riskIntelligence))
+	data := redactor.Data(original)
+
+	assert.Equal(t, "88.64.4.0", data.Network.IP)
+	assert.False(t, data.Network.AbuseContact.Valid)
+	assert.Equal(t, "Firefox", data.Client.HeaderUserAgent)
+	assert.Equal(t, "d87a30a5782a73a83c1544bb06332780", data.Client.TLSSignature.V.JA3)
+	// Other data is kept, and the original is not modified.
+	assert.Equal(t, original.RiskScores, data.RiskScores)
+	assert.Equal(t, original.Network.AS, data.Network.AS)
+	assert.Equal(t, "88.64.4.22", original.Network.IP)
+	assert.True(t, original.Network.AbuseContact.Valid)
+
+	// Raw JSON is redacted the same way.
+	raw, err := redactor.Raw(json.RawMessage(riskIntelligence))
+	require.NoError(t, err)
+	assert.Equal(t, data, decode(t, raw))
+	assert.NotContains(t, string(raw), "abuse.de@vodafone.com")
+	assert.NotContains(t, string(raw), "Macintosh")
+	// Fields that are not modeled, and large numbers, are kept.
+	assert.Contains(t, string(raw), `"future_field":{"nested":12345678901234567890}`)
+}
+
+func TestRedactorPseudonymizedProfile(t *testing.T) {
+	t.Parallel()
+
+	redactor := redact.MustNew(redact.PseudonymizedProfile(testKey))
+	data := redactor.Data(decode(t, []byte(riskIntelligence)))
+
+	assert.Regexp(t, `^[0-9a-f]{32}$`, data.Network.IP)
+	assert.Equal(t, redactor.PseudonymizeIP("88.64.4.22"), data.Network.IP)
+	assert.Equal(t, redactor.PseudonymizeIP("::ffff:88.64.4.22"), data.Network.IP)
+	assert.NotEqual(t, redactor.PseudonymizeIP("88.64.4.23"), data.Network.IP)
+
+	signature := data.Client.TLSSignature.V
+	assert.Regexp(t, `^[0-9a-f]{32}$`, signature.JA3)
+	assert.NotEqual(t, "d87a30a5782a73a83c1544bb06332780", signature.JA3)
+	assert.NotEqual(t, signature.JA3, signature.JA3N)
+	assert.NotEqual(t, signature.JA3, signature.JA4)
+
+	raw, err := redactor.Raw(json.RawMessage(riskIntelligence))
+	require.NoError(t, err)
+	assert.Equal(t, data, decode(t, raw))
+
+	// Pseudonyms depend on the key.
+	other := redact.MustNew(redact.PseudonymizedProfile([]byte("another key of at least 16 bytes")))
+	assert.NotEqual(t, other.PseudonymizeIP("88.64.4.22"), data.Network.IP)
+	assert.Empty(t, redact.MustNew(redact.DefaultProfile()).PseudonymizeIP("88.64.4.22"))
+}
+
+func TestRedactorIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode     redact.IPMode
+		ip       string
+		expected string
+	}{
+		{mode: redact.IPKeep, ip: "88.64.4.22", expected: "88.64.4.22"},
+		{mode: redact.IPTruncate, ip: "88.64.4.22", expected: "88.64.4.0"},
+		{mode: redact.IPTruncate, ip: "::ffff:88.64.4.22", expected: "88.64.4.0"},
+		{mode: redact.IPTruncate, ip: "2001:db8:1:2:3:4:5:6", expected: "2001:db8:1::"},
+		{mode: redact.IPTruncate, ip: "fe80::1%eth0", expected: "fe80::"},
+		{mode: redact.IPTruncate, ip: "not an ip", expected: ""},
+		{mode: redact.IPTruncate, ip: "", expected: ""},
+		{mode: redact.IPDrop, ip: "88.64.4.22", expected: ""},
+		{mode: redact.IPPseudonymize, ip: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		redactor := redact.MustNew(redact.Profile{IP: tt.mode, Key: testKey})
+
+		var data friendlycaptcha.RiskIntelligenceData
+		data.Network.IP = tt.ip
+		assert.Equal(t, tt.expected, redactor.Data(data).Network.IP, "%d %q", tt.mode, tt.ip)
+	}
+}
+
+func TestRedactorRaw(t *testing.T) {
+	t.Parallel()
+
+	redactor := redact.MustNew(redact.PseudonymizedProfile(testKey))
+
+	// Modules that are not enabled are null.
+	raw, err := redactor.Raw(json.RawMessage(`{
+		"risk_scores": null,
+		"network": {"ip": "88.64.4.22", "abuse_contact": null},
+		"client": {"header_user_agent": "curl/8.4.0", "tls_signature": null}
+	}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"risk_scores": null,
+		"network": {"ip": "`+redactor.PseudonymizeIP("88.64.4.22")+`", "abuse_contact": null},
+		"client": {"header_user_agent": "curl", "tls_signature": null}
+	}`, string(raw))
+
+	raw, err = redactor.Raw(json.RawMessage(`null`))
+	require.NoError(t, err)
+	assert.Equal(t, `null`, string(raw))
+
+	_, err = redactor.Raw(json.RawMessage(`[1, 2]`))
+	assert.Error(t, err)
+	_, err = redactor.Raw(json.RawMessage(`{"network":`))
+	assert.Error(t, err)
+
+	value, err := redactor.RawValue(null.ValueFrom(json.RawMessage(`{"network": {"ip": "88.64.4.22"}}`)))
+	require.NoError(t, err)
+	assert.True(t, value.Valid)
+	assert.NotContains(t, string(value.V), "88.64.4.22")
+
+	value, err = redactor.RawValue(null.Value[json.RawMessage]{})
+	require.NoError(t, err)
+	assert.False(t, value.Valid)
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := redact.New(redact.PseudonymizedProfile(nil))
+	assert.ErrorContains(t, err, "requires a key of at least 16 bytes")
+	_, err = redact.New(redact.Profile{PseudonymizeTLSSignatures: true, Key: []byte("short")})
+	assert.ErrorContains(t, err, "requires a key of at least 16 bytes")
+	_, err = redact.New(redact.Profile{IP: redact.IPMode(42)})
+	assert.ErrorContains(t, err, "unknown IP mode")
+	_, err = redact.New(redact.Profile{UserAgent: redact.UserAgentMode(42)})
+	assert.ErrorContains(t, err, "unknown User-Agent mode")
+
+	assert.Panics(t, func() { redact.MustNew(redact.PseudonymizedProfile(nil)) })
+}
+
+func TestUserAgentFamilyOf(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:146.0) Gecko/20100101 Firefox/146.0":                                                            "Firefox",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36":                                 "Chrome",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0":                   "Edge",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15":                           "Safari",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1": "Chrome",
+		"Mozilla/5.0 (Linux; Android 13; SM-S901B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36":      "Samsung Internet",
+		"Mozilla/5.0 (X11; Linux x86_64) HeadlessChrome/120.0.0.0":                                                                                        "Headless Chrome",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                                        "Bot",
+		"Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko":                                                                                   "Internet Explorer",
+		"curl/8.4.0":             "curl",
+		"python-requests/2.31.0": "python-requests",
+		"Mozilla/5.0":            "Other",
+		"John Doe's laptop/1.0":  "Other",
+		"":                       "",
+	}
+
+	for userAgent, expected := range tests {
+		assert.Equal(t, expected, redact.UserAgentFamilyOf(userAgent), userAgent)
+	}
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []friendlycaptcha.Event
+}
+
+func (s *recordingSink) Record(event friendlycaptcha.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestRedactorSink(t *testing.T) {
+	t.Parallel()
+
+	recorded := &recordingSink{}
+	sink := redact.MustNew(redact.DefaultProfile()).Sink(recorded)
+
+	sink.Record(friendlycaptcha.Event{EventID: "ev_1", RiskIntelligence: json.RawMessage(riskIntelligence)})
+	sink.Record(friendlycaptcha.Event{EventID: "ev_2"})
+	sink.Record(friendlycaptcha.Event{EventID: "ev_3", RiskIntelligence: json.RawMessage(`{`)})
+
+	require.Len(t, recorded.events, 3)
+	assert.Equal(t, "88.64.4.0", decode(t, recorded.events[0].RiskIntelligence).Network.IP)
+	assert.Nil(t, recorded.events[1].RiskIntelligence)
+	// Risk intelligence that can not be redacted is removed.
+	assert.Equal(t, "ev_3", recorded.events[2].EventID)
+	assert.Nil(t, recorded.events[2].RiskIntelligence)
+}
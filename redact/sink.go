@@ -0,0 +1,33 @@
+package redact
+
+import friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+
+// Sink returns an EventSink that redacts the risk intelligence of every event before passing it to the given sink:
+//
+//	sink, err := eventsink.OpenFile("events.jsonl")
+//	...
+//	client, err := friendlycaptcha.NewClient(
+//		friendlycaptcha.WithAPIKey(apiKey),
+//		friendlycaptcha.WithEventSink(redactor.Sink(sink)),
+//	)
+//
+// If the risk intelligence of an event can not be redacted, it is removed from the event.
+func (r *Redactor) Sink(sink friendlycaptcha.EventSink) friendlycaptcha.EventSink {
+	return &redactingSink{redactor: r, sink: sink}
+}
+
+type redactingSink struct {
+	redactor *Redactor
+	sink     friendlycaptcha.EventSink
+}
+
+func (s *redactingSink) Record(event friendlycaptcha.Event) {
+	if event.RiskIntelligence != nil {
+		redacted, err := s.redactor.Raw(event.RiskIntelligence)
+		if err != nil {
+			redacted = nil
+		}
+		event.RiskIntelligence = redacted
+	}
+	s.sink.Record(event)
+}
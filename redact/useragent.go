@@ -0,0 +1,70 @@
+package redact
+
+import "strings"
+
+// userAgentFamilies maps a token in the User-Agent header to the browser family. The order matters: most browsers
+// also claim to be Safari or Chrome, so the more specific tokens come first.
+var userAgentFamilies = []struct {
+	token  string
+	family string
+}{
+	{"edg/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex Browser"},
+	{"vivaldi/", "Vivaldi"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"headlesschrome/", "Headless Chrome"},
+	{"chrome/", "Chrome"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"safari/", "Safari"},
+	{"applewebkit/", "WebKit"},
+	{"bot", "Bot"},
+	{"crawler", "Bot"},
+	{"spider", "Bot"},
+}
+
+// UserAgentFamilyOf returns the browser family of a User-Agent header, e.g. "Firefox" for
+// "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:146.0) Gecko/20100101 Firefox/146.0". For other clients, the
+// product name without version is returned, e.g. "curl" for "curl/8.4.0". It returns "Other" if the header is not
+// recognized and an empty string if it is empty.
+func UserAgentFamilyOf(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return ""
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, f := range userAgentFamilies {
+		if strings.Contains(lower, f.token) {
+			return f.family
+		}
+	}
+
+	// Not a browser, e.g. "curl/8.4.0" or "python-requests/2.31.0".
+	product, _, _ := strings.Cut(userAgent, "/")
+	if product != userAgent && !strings.HasPrefix(lower, "mozilla/") && isProductName(product) {
+		return product
+	}
+	return "Other"
+}
+
+// isProductName returns whether s looks like a product name, which doesn't identify the user.
+func isProductName(s string) bool {
+	if len(s) == 0 || len(s) > 32 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+package friendlycaptcha
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Tenant is the configuration of one site in a Registry.
+type Tenant struct {
+	// ID identifies the tenant in the Registry. Defaults to the Sitekey.
+	ID string
+	// Sitekey of the tenant, sent with every request of its Client.
+	Sitekey string
+	// APIKey of the tenant. Defaults to the API key of the Registry options, if any.
+	APIKey string
+	// APIEndpoint of the tenant, e.g. "eu" or "global", see WithAPIEndpoint. Defaults to the endpoint of the Registry
+	// options, or "global".
+	APIEndpoint string
+	// Hosts are the request hosts of the tenant's sites, e.g. "www.example.com", used by Registry.Middleware.
+	Hosts []string
+	// Options are applied after the options of the Registry, e.g. WithStrictMode for a single tenant.
+	Options []ClientOption
+}
+
+// Registry holds a Client per tenant, for services that host sites with different sitekeys, API keys or regions. Its
+// lookups don't take locks, so that the request path is not slowed down while tenants are added or removed.
+//
+// The zero value is not usable, use NewRegistry. A Registry is safe for concurrent use.
+type Registry struct {
+	opts       []ClientOption
+	httpClient *http.Client
+
+	// mu serializes changes, readers load the current snapshot without locking.
+	mu       sync.Mutex
+	snapshot atomic.Pointer[registrySnapshot]
+}
+
+// registrySnapshot is an immutable view of the tenants of a Registry, it is replaced on every change.
+type registrySnapshot struct {
+	byID      map[string]registryEntry
+	bySitekey map[string]*Client
+	byHost    map[string]*Client
+}
+
+type registryEntry struct {
+	tenant Tenant
+	client *Client
+}
+
+// NewRegistry returns an empty Registry. The options are applied to the Client of every tenant, before the options of
+// the tenant itself, e.g. WithRetryPolicy or WithLogger. Options that take a value, like WithCircuitBreaker, share
+// that value between all tenants.
+//
+// All clients share one http.Client, so that connections to the Friendly Captcha API are pooled. It is the client of
+// WithHTTPClient if given, otherwise the one NewClient creates from options like WithTimeout and WithProxy. The
+// options of a tenant can't change the transport, but WithTimeout can be used to set a different timeout.
+func NewRegistry(opts ...ClientOption) (*Registry, error) {
+	template, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{opts: opts, httpClient: template.HTTPClient}
+	r.snapshot.Store(&registrySnapshot{})
+	return r, nil
+}
+
+// Add adds a tenant, or replaces the tenant with the same ID. It returns an error if the options of the tenant are
+// invalid, or if its sitekey or one of its hosts belongs to another tenant.
+func (r *Registry) Add(tenant Tenant) error {
+	if tenant.ID == "" {
+		tenant.ID = tenant.Sitekey
+	}
+	if tenant.ID == "" {
+		return fmt.Errorf("tenant must have an ID or sitekey")
+	}
+	tenant.Hosts = normalizeHosts(tenant.Hosts)
+	tenant.Options = append([]ClientOption(nil), tenant.Options...)
+
+	client, err := NewClient(r.tenantOptions(tenant)...)
+	if err != nil {
+		return fmt.Errorf("invalid options for tenant %q: %w", tenant.ID, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.snapshot.Load()
+	if err := checkConflicts(current, tenant); err != nil {
+		client.release()
+		return err
+	}
+
+	byID := make(map[string]registryEntry, len(current.byID)+1)
+	for id, entry := range current.byID {
+		byID[id] = entry
+	}
+	if replaced, ok := byID[tenant.ID]; ok {
+		replaced.client.release()
+	}
+	byID[tenant.ID] = registryEntry{tenant: tenant, client: client}
+	r.snapshot.Store(newRegistrySnapshot(byID))
+	return nil
+}
+
+// checkConflicts returns an error if the sitekey or one of the hosts of the tenant belongs to another tenant.
+func checkConflicts(current *registrySnapshot, tenant Tenant) error {
+	for id, entry := range current.byID {
+		if id == tenant.ID {
+			continue
+		}
+		if tenant.Sitekey != "" && entry.tenant.Sitekey == tenant.Sitekey {
+			return fmt.Errorf("sitekey %q of tenant %q already belongs to tenant %q", tenant.Sitekey, tenant.ID, id)
+		}
+		for _, host := range tenant.Hosts {
+			for _, other := range entry.tenant.Hosts {
+				if host == other {
+					return fmt.Errorf("host %q of tenant %q already belongs to tenant %q", host, tenant.ID, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Remove removes the tenant with the given ID, it returns false if there is no such tenant. Calls that already got the
+// tenant's Client can still use it.
+func (r *Registry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.snapshot.Load()
+	removed, ok := current.byID[id]
+	if !ok {
+		return false
+	}
+	removed.client.release()
+	byID := make(map[string]registryEntry, len(current.byID))
+	for otherID, entry := range current.byID {
+		if otherID != id {
+			byID[otherID] = entry
+		}
+	}
+	r.snapshot.Store(newRegistrySnapshot(byID))
+	return true
+}
+
+// Client returns the Client of the tenant with the given ID.
+func (r *Registry) Client(id string) (*Client, bool) {
+	entry, ok := r.snapshot.Load().byID[id]
+	return entry.client, ok
+}
+
+// ClientForSitekey returns the Client of the tenant with the given sitekey.
+func (r *Registry) ClientForSitekey(sitekey string) (*Client, bool) {
+	client, ok := r.snapshot.Load().bySitekey[sitekey]
+	return client, ok
+}
+
+// ClientForHost returns the Client of the tenant with the given host, e.g. the `Host` header of a request. If there is
+// no tenant for the host including its port, the port is ignored.
+func (r *Registry) ClientForHost(host string) (*Client, bool) {
+	byHost := r.snapshot.Load().byHost
+	host = strings.ToLower(host)
+	if client, ok := byHost[host]; ok {
+		return client, true
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		client, ok := byHost[hostname]
+		return client, ok
+	}
+	return nil, false
+}
+
+// Tenants returns the tenants in the registry, sorted by ID.
+func (r *Registry) Tenants() []Tenant {
+	byID := r.snapshot.Load().byID
+	tenants := make([]Tenant, 0, len(byID))
+	for _, entry := range byID {
+		tenants = append(tenants, entry.tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// Middleware is like Client.Middleware, but verifies captcha responses with the Client of the tenant of the request's
+// `Host` header, see Tenant.Hosts. Requests for unknown hosts are rejected: the result's `RequestError()` is
+// ErrUnknownTenant.
+func (r *Registry) Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return newMiddleware(func(req *http.Request) (Verifier, error) {
+		client, ok := r.ClientForHost(req.Host)
+		if !ok {
+			return nil, fmt.Errorf("%w: no tenant for host %q", ErrUnknownTenant, req.Host)
+		}
+		return client, nil
+	}, opts)
+}
+
+// tenantOptions returns the options for the Client of the tenant.
+func (r *Registry) tenantOptions(tenant Tenant) []ClientOption {
+	opts := append([]ClientOption(nil), r.opts...)
+	opts = append(opts, WithHTTPClient(r.httpClient), WithSitekey(tenant.Sitekey))
+	if tenant.APIKey != "" {
+		opts = append(opts, WithAPIKey(tenant.APIKey))
+	}
+	if tenant.APIEndpoint != "" {
+		opts = append(opts, WithAPIEndpoint(tenant.APIEndpoint))
+	}
+	return append(opts, tenant.Options...)
+}
+
+func newRegistrySnapshot(byID map[string]registryEntry) *registrySnapshot {
+	s := &registrySnapshot{
+		byID:      byID,
+		bySitekey: make(map[string]*Client, len(byID)),
+		byHost:    make(map[string]*Client),
+	}
+	for _, entry := range byID {
+		if entry.tenant.Sitekey != "" {
+			s.bySitekey[entry.tenant.Sitekey] = entry.client
+		}
+		for _, host := range entry.tenant.Hosts {
+			s.byHost[host] = entry.client
+		}
+	}
+	return s
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			normalized = append(normalized, host)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,234 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry, err := NewRegistry(WithAPIKey("shared-key"))
+	require.NoError(t, err)
+
+	require.NoError(t, registry.Add(Tenant{ID: "a", Sitekey: "sitekey-a", Hosts: []string{"A.example.com "}}))
+	require.NoError(t, registry.Add(Tenant{Sitekey: "sitekey-b", APIKey: "key-b", APIEndpoint: "eu"}))
+
+	a, ok := registry.Client("a")
+	require.True(t, ok)
+	assert.Equal(t, "sitekey-a", a.Sitekey)
+	assert.Equal(t, "shared-key", a.APIKey)
+	assert.Equal(t, globalAPIEndpoint, a.APIEndpoint)
+
+	b, ok := registry.Client("sitekey-b")
+	require.True(t, ok, "the ID defaults to the sitekey")
+	assert.Equal(t, "key-b", b.APIKey)
+	assert.Equal(t, euAPIEndpoint, b.APIEndpoint)
+	assert.Same(t, a.HTTPClient, b.HTTPClient)
+
+	client, ok := registry.ClientForSitekey("sitekey-b")
+	assert.True(t, ok)
+	assert.Same(t, b, client)
+	for _, host := range []string{"a.example.com", "A.EXAMPLE.COM", "a.example.com:8080"} {
+		client, ok = registry.ClientForHost(host)
+		assert.True(t, ok, host)
+		assert.Same(t, a, client, host)
+	}
+	_, ok = registry.ClientForHost("b.example.com")
+	assert.False(t, ok)
+
+	tenants := registry.Tenants()
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "a", tenants[0].ID)
+	assert.Equal(t, []string{"a.example.com"}, tenants[0].Hosts)
+	assert.Equal(t, "sitekey-b", tenants[1].ID)
+
+	// Replacing a tenant replaces its lookups.
+	require.NoError(t, registry.Add(Tenant{ID: "a", Sitekey: "sitekey-a2"}))
+	_, ok = registry.ClientForSitekey("sitekey-a")
+	assert.False(t, ok)
+	_, ok = registry.ClientForHost("a.example.com")
+	assert.False(t, ok)
+	_, ok = registry.ClientForSitekey("sitekey-a2")
+	assert.True(t, ok)
+
+	assert.True(t, registry.Remove("a"))
+	assert.False(t, registry.Remove("a"))
+	_, ok = registry.Client("a")
+	assert.False(t, ok)
+	assert.Len(t, registry.Tenants(), 1)
+}
+
+func TestRegistryErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRegistry(WithAPIEndpoint(""))
+	assert.Error(t, err)
+
+	registry, err := NewRegistry()
+	require.NoError(t, err)
+	assert.ErrorContains(t, registry.Add(Tenant{}), "must have an ID or sitekey")
+	assert.ErrorContains(t, registry.Add(Tenant{ID: "a"}), `invalid options for tenant "a"`)
+
+	require.NoError(t, registry.Add(Tenant{ID: "a", Sitekey: "sitekey", APIKey: "key", Hosts: []string{"example.com"}}))
+	err = registry.Add(Tenant{ID: "b", Sitekey: "sitekey", APIKey: "key"})
+	assert.ErrorContains(t, err, `already belongs to tenant "a"`)
+	err = registry.Add(Tenant{ID: "b", APIKey: "key", Hosts: []string{"EXAMPLE.com"}})
+	assert.ErrorContains(t, err, `host "example.com" of tenant "b" already belongs to tenant "a"`)
+	assert.Len(t, registry.Tenants(), 1)
+}
+
+func TestRegistryRoutesRequests(t *testing.T) {
+	t.Parallel()
+
+	globalHandler, globalRequests := recordRequests()
+	global, _ := newTestServer(t, globalHandler)
+	euHandler, euRequests := recordRequests()
+	eu, _ := newTestServer(t, euHandler)
+	httpClient := &http.Client{}
+	registry, err := NewRegistry(WithAPIKey("shared-key"), WithAPIEndpoint(global.URL), WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(Tenant{Sitekey: "sitekey-global"}))
+	require.NoError(t, registry.Add(Tenant{Sitekey: "sitekey-eu", APIKey: "eu-key", APIEndpoint: eu.URL}))
+
+	for _, sitekey := range []string{"sitekey-global", "sitekey-eu"} {
+		client, ok := registry.ClientForSitekey(sitekey)
+		require.True(t, ok)
+		assert.Same(t, httpClient, client.HTTPClient)
+		assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").ShouldAccept())
+	}
+
+	require.Len(t, globalRequests(), 1)
+	assert.Equal(t, "sitekey-global", globalRequests()[0].sitekey)
+	assert.Equal(t, "shared-key", globalRequests()[0].header.Get("X-Api-Key"))
+	require.Len(t, euRequests(), 1)
+	assert.Equal(t, "sitekey-eu", euRequests()[0].sitekey)
+	assert.Equal(t, "eu-key", euRequests()[0].header.Get("X-Api-Key"))
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	t.Parallel()
+
+	registry, err := NewRegistry(WithAPIKey("key"))
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(Tenant{Sitekey: "stable"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("tenant-%d-%d", i, j)
+				assert.NoError(t, registry.Add(Tenant{Sitekey: id, Hosts: []string{id + ".example.com"}}))
+				assert.True(t, registry.Remove(id))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				_, ok := registry.ClientForSitekey("stable")
+				assert.True(t, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, registry.Tenants(), 1)
+}
+
+func TestRegistryMiddleware(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t, respondIfValid)
+	registry, err := NewRegistry(WithAPIKey("test-key"), WithAPIEndpoint(server.URL))
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(Tenant{Sitekey: "sitekey", Hosts: []string{"example.com"}}))
+
+	var rejected VerifyResult
+	handler := registry.Middleware(WithRejectionHandler(func(w http.ResponseWriter, r *http.Request, result VerifyResult) {
+		rejected = result
+		DefaultRejectionHandler(w, r, result)
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method   string
+		host     string
+		response string
+		expected int
+	}{
+		{method: http.MethodPost, host: "example.com", response: "valid", expected: http.StatusOK},
+		{method: http.MethodPost, host: "example.com:443", response: "valid", expected: http.StatusOK},
+		{method: http.MethodPost, host: "example.com", response: "invalid", expected: http.StatusForbidden},
+		{method: http.MethodPost, host: "example.org", response: "valid", expected: http.StatusForbidden},
+		{method: http.MethodGet, host: "example.org", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{ResponseFormFieldName: {tt.response}}
+		req := httptest.NewRequest(tt.method, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, tt.expected, rec.Code, "%s %s %s", tt.method, tt.host, tt.response)
+	}
+
+	assert.ErrorIs(t, rejected.RequestError(), ErrUnknownTenant)
+	assert.False(t, rejected.ShouldAccept())
+	assert.Equal(t, FailureUnknown, rejected.FailureClass())
+}
+
+func TestRegistryLimiterGauges(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	limiter := NewConcurrencyLimiter(10, time.Second)
+	registry, err := NewRegistry(
+		WithAPIKey("shared-key"),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithConcurrencyLimiter(limiter),
+	)
+	require.NoError(t, err)
+
+	// The gauges of a shared limiter are registered once.
+	require.NoError(t, registry.Add(Tenant{Sitekey: "a"}))
+	require.NoError(t, registry.Add(Tenant{Sitekey: "b"}))
+	assert.Equal(t, []int{2}, gaugeClients(limiter))
+
+	// Replacing a tenant, or failing to add one, releases the registration of its client.
+	require.NoError(t, registry.Add(Tenant{Sitekey: "a", Options: []ClientOption{WithStrictMode(true)}}))
+	require.Error(t, registry.Add(Tenant{ID: "c", Sitekey: "b"}))
+	assert.Equal(t, []int{2}, gaugeClients(limiter))
+
+	// Removing a tenant with its own limiter unregisters its gauges.
+	require.NoError(t, registry.Add(Tenant{Sitekey: "d", Options: []ClientOption{WithMaxInFlight(1, 0)}}))
+	d, _ := registry.Client("d")
+	assert.Equal(t, []int{1}, gaugeClients(d.ConcurrencyLimiter))
+	registry.Remove("d")
+	assert.Empty(t, gaugeClients(d.ConcurrencyLimiter))
+
+	registry.Remove("a")
+	registry.Remove("b")
+	assert.Empty(t, gaugeClients(limiter))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			_, isGauge := m.Data.(metricdata.Gauge[int64])
+			assert.False(t, isGauge, m.Name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package friendlycaptcha
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultReplayTTL is how long captcha responses are remembered by default. The ttl should be at least as long as a
+// captcha response is accepted by the Friendly Captcha API, after that the API rejects it with `response_timeout`.
+const DefaultReplayTTL = time.Hour
+
+// DefaultReplayStoreCapacity is the default number of captcha responses a MemoryReplayStore remembers.
+const DefaultReplayStoreCapacity = 100_000
+
+// ReplayStore remembers the captcha responses a Client has seen, see WithReplayProtection. Keys are hashes of
+// captcha responses. Implementations must be safe for concurrent use, e.g. MemoryReplayStore for a single instance
+// or a store backed by a shared cache for multiple instances of a service.
+type ReplayStore interface {
+	// CheckAndStore stores the key for the given ttl, and reports whether it was already stored and has not expired.
+	// It must do so atomically: of concurrent calls with the same key, only one may return false.
+	CheckAndStore(ctx context.Context, key string, ttl time.Duration) (seen bool, err error)
+	// Forget removes the key, so that a captcha response that could not be verified can be submitted again.
+	Forget(ctx context.Context, key string) error
+}
+
+// WithReplayProtection rejects captcha responses that were already submitted within the ttl, without sending them to
+// the Friendly Captcha API. Results of such calls return true for `IsReplayed()` and are always rejected, also if the
+// API is unreachable and the failure policy would accept the response otherwise.
+//
+// The API itself rejects reused captcha responses with `response_duplicate`, but only if it can be reached. A
+// response is remembered as soon as it is submitted, and forgotten again if it was rejected because it could not be
+// verified, so the user can retry. If the store returns an error, the response is verified without replay protection.
+//
+// A ttl of 0 uses DefaultReplayTTL. The protection is only as good as the store: a MemoryReplayStore only protects a
+// single instance of your service, and forgets the oldest responses once it is full.
+func WithReplayProtection(store ReplayStore, ttl time.Duration) ClientOption {
+	return func(c *Client) error {
+		if store == nil {
+			return fmt.Errorf("replay store must not be nil")
+		}
+		if ttl < 0 {
+			return fmt.Errorf("replay ttl must not be negative")
+		}
+		if ttl == 0 {
+			ttl = DefaultReplayTTL
+		}
+		c.ReplayStore = store
+		c.ReplayTTL = ttl
+		return nil
+	}
+}
+
+// replayKey returns the key of a captcha response in the ReplayStore.
+func replayKey(captchaResponse string) string {
+	sum := sha256.Sum256([]byte(captchaResponse))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkReplay returns the key of the captcha response and whether it was seen before. The key is empty if replay
+// protection is disabled or the store failed.
+func (frc *Client) checkReplay(ctx context.Context, captchaResponse string) (string, bool) {
+	// Let the API reject empty responses with `response_missing`.
+	if frc.ReplayStore == nil || captchaResponse == "" {
+		return "", false
+	}
+	key := replayKey(captchaResponse)
+	ttl := frc.ReplayTTL
+	if ttl <= 0 {
+		ttl = DefaultReplayTTL
+	}
+	seen, err := frc.ReplayStore.CheckAndStore(ctx, key, ttl)
+	if err != nil {
+		return "", false
+	}
+	return key, seen
+}
+
+// forgetReplay removes the captcha response from the ReplayStore if it wasn't consumed: it could not be verified and
+// was rejected, so the user should be able to submit it again.
+func (frc *Client) forgetReplay(ctx context.Context, key string, result VerifyResult) {
+	if key == "" || result.WasAbleToVerify() || result.ShouldAccept() {
+		return
+	}
+	// The context may be canceled, forgetting must still succeed.
+	_ = frc.ReplayStore.Forget(context.WithoutCancel(ctx), key)
+}
+
+// MemoryReplayStore is an in-memory ReplayStore that remembers up to a fixed number of keys. Once it is full, the
+// oldest keys are forgotten first.
+type MemoryReplayStore struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// byAge contains the entries from newest to oldest. Entries are not moved when they are checked again, so that this
+	// is also the order they expire in if they were stored with the same ttl.
+	byAge *list.List
+}
+
+type replayEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+var _ ReplayStore = (*MemoryReplayStore)(nil)
+
+// NewMemoryReplayStore returns a MemoryReplayStore that remembers up to capacity keys. A capacity of 0 or less uses
+// DefaultReplayStoreCapacity. Every key takes roughly 200 bytes of memory.
+func NewMemoryReplayStore(capacity int) *MemoryReplayStore {
+	if capacity <= 0 {
+		capacity = DefaultReplayStoreCapacity
+	}
+	return &MemoryReplayStore{
+		capacity: capacity,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		byAge:    list.New(),
+	}
+}
+
+// CheckAndStore implements ReplayStore.
+func (s *MemoryReplayStore) CheckAndStore(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*replayEntry)
+		if now.Before(entry.expiresAt) {
+			return true, nil
+		}
+		s.remove(element)
+	}
+
+	s.removeExpired(now)
+	for s.byAge.Len() >= s.capacity {
+		s.remove(s.byAge.Back())
+	}
+	s.entries[key] = s.byAge.PushFront(&replayEntry{key: key, expiresAt: now.Add(ttl)})
+	return false, nil
+}
+
+// Forget implements ReplayStore.
+func (s *MemoryReplayStore) Forget(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+// Len returns the number of keys in the store, including keys that expired but were not removed yet.
+func (s *MemoryReplayStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byAge.Len()
+}
+
+// removeExpired removes expired entries from the oldest end, it stops at the first entry that has not expired.
+func (s *MemoryReplayStore) removeExpired(now time.Time) {
+	for element := s.byAge.Back(); element != nil; element = s.byAge.Back() {
+		if now.Before(element.Value.(*replayEntry).expiresAt) {
+			return
+		}
+		s.remove(element)
+	}
+}
+
+func (s *MemoryReplayStore) remove(element *list.Element) {
+	s.byAge.Remove(element)
+	delete(s.entries, element.Value.(*replayEntry).key)
+}
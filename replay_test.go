@@ -0,0 +1,236 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryReplayStore(capacity int) (*MemoryReplayStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryReplayStore(capacity)
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryReplayStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, clock := newTestMemoryReplayStore(3)
+
+	seen, err := store.CheckAndStore(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, seen)
+	seen, _ = store.CheckAndStore(ctx, "a", time.Minute)
+	assert.True(t, seen)
+
+	// Keys expire after the ttl.
+	clock.Advance(time.Minute)
+	seen, _ = store.CheckAndStore(ctx, "a", time.Minute)
+	assert.False(t, seen)
+
+	// Forgotten keys are new again.
+	require.NoError(t, store.Forget(ctx, "a"))
+	require.NoError(t, store.Forget(ctx, "unknown"))
+	seen, _ = store.CheckAndStore(ctx, "a", time.Minute)
+	assert.False(t, seen)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestMemoryReplayStoreEviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, clock := newTestMemoryReplayStore(3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		seen, _ := store.CheckAndStore(ctx, key, time.Hour)
+		assert.False(t, seen)
+	}
+	// Checking "a" again doesn't make it newer, it is still the oldest key.
+	seen, _ := store.CheckAndStore(ctx, "a", time.Hour)
+	assert.True(t, seen)
+
+	seen, _ = store.CheckAndStore(ctx, "d", time.Hour)
+	assert.False(t, seen)
+	assert.Equal(t, 3, store.Len())
+	for key, expected := range map[string]bool{"b": true, "c": true, "d": true} {
+		seen, _ := store.CheckAndStore(ctx, key, time.Hour)
+		assert.Equal(t, expected, seen, key)
+	}
+	seen, _ = store.CheckAndStore(ctx, "a", time.Hour)
+	assert.False(t, seen, "a was evicted")
+
+	// Expired keys are removed before keys that are still valid are evicted.
+	clock.Advance(2 * time.Hour)
+	seen, _ = store.CheckAndStore(ctx, "e", time.Hour)
+	assert.False(t, seen)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestMemoryReplayStoreExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, clock := newTestMemoryReplayStore(10)
+
+	_, _ = store.CheckAndStore(ctx, "a", time.Hour)
+	clock.Advance(30 * time.Minute)
+	_, _ = store.CheckAndStore(ctx, "b", time.Hour)
+	seen, _ := store.CheckAndStore(ctx, "a", time.Hour)
+	assert.True(t, seen)
+
+	// "a" expired, even though it was checked after "b" was stored.
+	clock.Advance(45 * time.Minute)
+	_, _ = store.CheckAndStore(ctx, "c", time.Hour)
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestMemoryReplayStoreConcurrent(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryReplayStore(0)
+	var firsts atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen, err := store.CheckAndStore(context.Background(), "key", time.Minute)
+			if err == nil && !seen {
+				firsts.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), firsts.Load())
+}
+
+type failingReplayStore struct{}
+
+func (failingReplayStore) CheckAndStore(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingReplayStore) Forget(ctx context.Context, key string) error {
+	return errors.New("store unavailable")
+}
+
+func TestClientWithReplayProtection(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t)
+	store := NewMemoryReplayStore(0)
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithReplayProtection(store, 0),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultReplayTTL, client.ReplayTTL)
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.ShouldAccept())
+	assert.False(t, result.IsReplayed())
+
+	result = client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.IsReplayed())
+	assert.False(t, result.ShouldAccept())
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureReplayed, result.FailureClass())
+	assert.ErrorIs(t, result.RequestError(), ErrResponseReplayed)
+	assert.Equal(t, 0, result.Attempts())
+	assert.Equal(t, int32(1), requests.Load(), "replays are not sent to the API")
+
+	// Other responses are not affected.
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "other").ShouldAccept())
+	// Empty responses are left to the API.
+	client.VerifyCaptchaResponse(context.Background(), "")
+	assert.False(t, client.VerifyCaptchaResponse(context.Background(), "").IsReplayed())
+}
+
+func TestClientWithReplayProtectionDuringOutage(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable))
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			client, err := NewClient(
+				WithAPIKey("test-key"),
+				WithAPIEndpoint(server.URL),
+				WithStrictMode(strict),
+				WithReplayProtection(NewMemoryReplayStore(0), time.Minute),
+			)
+			require.NoError(t, err)
+
+			result := client.VerifyCaptchaResponse(context.Background(), "response")
+			assert.False(t, result.WasAbleToVerify())
+			assert.Equal(t, !strict, result.ShouldAccept())
+
+			result = client.VerifyCaptchaResponse(context.Background(), "response")
+			if strict {
+				// The response was rejected without being verified, so the user can try again.
+				assert.False(t, result.IsReplayed())
+				assert.Equal(t, FailureServerError, result.FailureClass())
+			} else {
+				// The response was accepted while the API was down, it can't be accepted again.
+				assert.True(t, result.IsReplayed())
+			}
+			assert.False(t, result.ShouldAccept())
+		})
+	}
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClientWithReplayProtectionCanceled(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t)
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithReplayProtection(NewMemoryReplayStore(0), time.Minute),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := client.VerifyCaptchaResponse(ctx, "response")
+	assert.True(t, result.IsContextCanceled())
+
+	// The canceled call did not consume the response.
+	result = client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.True(t, result.ShouldAccept())
+}
+
+func TestClientWithReplayProtectionStoreError(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t)
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithReplayProtection(failingReplayStore{}, time.Minute),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").ShouldAccept())
+	}
+	assert.Equal(t, int32(2), requests.Load())
+
+	_, err = NewClient(WithAPIKey("test-key"), WithReplayProtection(nil, 0))
+	assert.Error(t, err)
+	_, err = NewClient(WithAPIKey("test-key"), WithReplayProtection(failingReplayStore{}, -time.Second))
+	assert.Error(t, err)
+}
@@ -15,8 +15,13 @@ type VerifyResult struct {
 	// Status is the HTTP Response status code of the request to the Friendly Captcha API.
 	Status int
 
-	response VerifyResponse
-	strict   bool
+	response      VerifyResponse
+	strict        bool
+	failurePolicy *FailurePolicy
+	failure       FailureClass
+	errorCode     ErrorCode
+	attempts      int
+	coalesced     bool
 
 	// The error that occurred during verification, if any.
 	err error
@@ -25,13 +30,17 @@ type VerifyResult struct {
 // NewVerifyResult returns a new VerifyResult with the given response, status code, strict mode and error.
 // This is generally only useful if you want to create a VerifyResult manually for testing purposes.
 func NewVerifyResult(response VerifyResponse, status int, strict bool, err error) VerifyResult {
-	return VerifyResult{
+	result := VerifyResult{
 		Success:  response.Success,
 		Status:   status,
 		response: response,
 		strict:   strict,
 		err:      err,
 	}
+	if response.Error != nil {
+		result.errorCode = response.Error.ErrorCode
+	}
+	return result
 }
 
 // RequestError returns the error, if any (nil otherwise).
@@ -49,23 +58,23 @@ func (r VerifyResult) Strict() bool {
 
 // ShouldAccept returns true if you should allow the request to pass through.
 // It is possible that verification wasn't possible, perhaps the API is unavailable. In that case this function will
-// also return true, unless you enable `strict` mode for the client.
+// also return true, unless you enable `strict` mode for the client or configured a FailurePolicy that rejects this
+// class of failure (see `FailureClass`).
+//
+// If verification wasn't possible because the context passed to the client was canceled, this function always
+// returns false. It also returns false if the captcha response was verified but rejected by the client, e.g. because
+// the origin is not allowed (see `IsOriginNotAllowed`) or the challenge is too old (see `IsChallengeTooOld`).
 func (r VerifyResult) ShouldAccept() bool {
 	if r.WasAbleToVerify() {
-		return r.response.Success
+		return r.response.Success && !r.isRejected()
 	}
 	if r.err != nil {
-		if r.strict { // If Strict mode is enabled, we do not accept any captcha if there was an error.
-			return false
+		policy := r.failurePolicy
+		if policy == nil { // Created with NewVerifyResult, fall back to the strict mode presets.
+			preset := strictFailurePolicy(r.strict)
+			policy = &preset
 		}
-		if errors.Is(r.err, ErrVerificationRequest) ||
-			errors.Is(
-				r.err,
-				ErrVerificationFailedDueToClientError,
-			) { // Failure to talk to Friendly Captcha verification API or client error (e.g. wrong API key)
-			return true
-		}
-		return false
+		return policy.Action(r.FailureClass(), r.errorCode) == FailOpen
 	}
 
 	panic(
@@ -87,6 +96,54 @@ func (r VerifyResult) IsRequestError() bool {
 	return r.err != nil && errors.Is(r.err, ErrVerificationRequest)
 }
 
+// IsCircuitOpen returns true if no request was sent to the Friendly Captcha API because the circuit breaker is open.
+// In that case `IsRequestError` also returns true.
+func (r VerifyResult) IsCircuitOpen() bool {
+	return r.err != nil && errors.Is(r.err, ErrCircuitOpen)
+}
+
+// IsOverloaded returns true if no request was sent to the Friendly Captcha API because too many calls were in flight,
+// see WithMaxInFlight. In that case `IsRequestError` also returns true.
+func (r VerifyResult) IsOverloaded() bool {
+	return r.err != nil && errors.Is(r.err, ErrOverloaded)
+}
+
+// IsContextCanceled returns true if verification didn't complete because the context passed to the client was
+// canceled or its deadline was exceeded, e.g. because the incoming HTTP request was aborted.
+func (r VerifyResult) IsContextCanceled() bool {
+	return r.err != nil && errors.Is(r.err, ErrContextCanceled)
+}
+
+// IsReplayed returns true if the captcha response was rejected without asking the Friendly Captcha API, because it
+// was already submitted before (see WithReplayProtection).
+func (r VerifyResult) IsReplayed() bool {
+	return r.err != nil && errors.Is(r.err, ErrResponseReplayed)
+}
+
+// IsCoalesced returns true if the result is based on the request of another call with the same captcha response and
+// sitekey, which was either in flight at the same time or completed shortly before (see WithResponseCoalescing). Use
+// this to avoid performing the protected action twice, e.g. for a double-clicked submit button.
+func (r VerifyResult) IsCoalesced() bool {
+	return r.coalesced
+}
+
+// IsOriginNotAllowed returns true if the captcha response was verified successfully, but rejected because the
+// challenge was solved on an origin that is not allowed (see WithAllowedOrigins). The error contains the origin.
+func (r VerifyResult) IsOriginNotAllowed() bool {
+	return r.err != nil && errors.Is(r.err, ErrOriginNotAllowed)
+}
+
+// IsChallengeTooOld returns true if the captcha response was verified successfully, but rejected because the
+// challenge was solved too long ago (see WithMaxChallengeAge).
+func (r VerifyResult) IsChallengeTooOld() bool {
+	return r.err != nil && errors.Is(r.err, ErrChallengeTooOld)
+}
+
+// isRejected returns whether the client rejected a captcha response that the Friendly Captcha API verified.
+func (r VerifyResult) isRejected() bool {
+	return isRejection(r.err)
+}
+
 // This is an error that is not due to a connection error, but due to a client error (e.g. wrong API key).
 // You should log this and notify yourself and fix this as soon as possible.
 //
@@ -95,6 +152,20 @@ func (r VerifyResult) IsErrorDueToClientError() bool {
 	return r.err != nil && errors.Is(r.err, ErrVerificationFailedDueToClientError)
 }
 
+// FailureClass returns why verification wasn't possible, or FailureNone if it was.
+func (r VerifyResult) FailureClass() FailureClass {
+	if r.failure != FailureNone || r.err == nil {
+		return r.failure
+	}
+	return classifyVerifyError(r.Status, r.err)
+}
+
+// ErrorCode returns the error code the Friendly Captcha API responded with, if any. Unlike `Response().Error` this is
+// also available for non-200 responses, e.g. `auth_invalid`.
+func (r VerifyResult) ErrorCode() ErrorCode {
+	return r.errorCode
+}
+
 // Response returns the response from the Friendly Captcha API.
 func (r VerifyResult) Response() VerifyResponse {
 	return r.response
@@ -105,6 +176,12 @@ func (r VerifyResult) HTTPStatusCode() int {
 	return r.Status
 }
 
+// Attempts returns the number of requests that were sent to the Friendly Captcha API, which is more than 1 if
+// failed requests were retried (see RetryPolicy). It is 0 if no request was sent.
+func (r VerifyResult) Attempts() int {
+	return r.attempts
+}
+
 // WasAbleToVerify returns true if the captcha could be verified. If this is false, you should log the reason why
 // and investigate (you can retrieve the error using the `RequestError` method). The `IsErrorDueToClientError` method
 // will tell you if the error was due to a client error (e.g. wrong API key) - which will require your action to fix.
@@ -120,7 +197,10 @@ type RiskIntelligenceRetrieveResult struct {
 	// Status is the HTTP response status code of the request to the Friendly Captcha API.
 	Status int
 
-	response RiskIntelligenceRetrieveResponse
+	response  RiskIntelligenceRetrieveResponse
+	failure   FailureClass
+	errorCode ErrorCode
+	attempts  int
 
 	// The error that occurred during retrieval, if any.
 	err error
@@ -133,12 +213,16 @@ func NewRiskIntelligenceRetrieveResult(
 	status int,
 	err error,
 ) RiskIntelligenceRetrieveResult {
-	return RiskIntelligenceRetrieveResult{
+	result := RiskIntelligenceRetrieveResult{
 		Success:  response.Success,
 		Status:   status,
 		response: response,
 		err:      err,
 	}
+	if response.Error != nil {
+		result.errorCode = response.Error.ErrorCode
+	}
+	return result
 }
 
 // RequestError returns the error, if any (nil otherwise).
@@ -160,11 +244,40 @@ func (r RiskIntelligenceRetrieveResult) IsRequestError() bool {
 	return r.err != nil && errors.Is(r.err, ErrRiskIntelligenceRetrieveRequest)
 }
 
+// IsCircuitOpen returns true if no request was sent to the Friendly Captcha API because the circuit breaker is open.
+// In that case `IsRequestError` also returns true.
+func (r RiskIntelligenceRetrieveResult) IsCircuitOpen() bool {
+	return r.err != nil && errors.Is(r.err, ErrCircuitOpen)
+}
+
+// IsOverloaded returns true if no request was sent to the Friendly Captcha API because too many calls were in flight,
+// see WithMaxInFlight. In that case `IsRequestError` also returns true.
+func (r RiskIntelligenceRetrieveResult) IsOverloaded() bool {
+	return r.err != nil && errors.Is(r.err, ErrOverloaded)
+}
+
+// IsContextCanceled returns true if retrieval didn't complete because the context passed to the client was canceled
+// or its deadline was exceeded.
+func (r RiskIntelligenceRetrieveResult) IsContextCanceled() bool {
+	return r.err != nil && errors.Is(r.err, ErrContextCanceled)
+}
+
 // IsErrorDueToClientError returns true for non-200 server responses, typically caused by invalid credentials or payload.
 func (r RiskIntelligenceRetrieveResult) IsErrorDueToClientError() bool {
 	return r.err != nil && errors.Is(r.err, ErrRiskIntelligenceRetrieveFailedDueToClientError)
 }
 
+// FailureClass returns why retrieval wasn't possible, or FailureNone if it was.
+func (r RiskIntelligenceRetrieveResult) FailureClass() FailureClass {
+	return r.failure
+}
+
+// ErrorCode returns the error code the Friendly Captcha API responded with, if any. Unlike `Response().Error` this is
+// also available for non-200 responses, e.g. `auth_invalid`.
+func (r RiskIntelligenceRetrieveResult) ErrorCode() ErrorCode {
+	return r.errorCode
+}
+
 // Response returns the response from the Friendly Captcha API.
 func (r RiskIntelligenceRetrieveResult) Response() RiskIntelligenceRetrieveResponse {
 	return r.response
@@ -175,6 +288,12 @@ func (r RiskIntelligenceRetrieveResult) HTTPStatusCode() int {
 	return r.Status
 }
 
+// Attempts returns the number of requests that were sent to the Friendly Captcha API, which is more than 1 if
+// failed requests were retried (see RetryPolicy). It is 0 if no request was sent.
+func (r RiskIntelligenceRetrieveResult) Attempts() int {
+	return r.attempts
+}
+
 // WasAbleToRetrieve returns true if retrieval succeeded and the server returned HTTP 200.
 func (r RiskIntelligenceRetrieveResult) WasAbleToRetrieve() bool {
 	return r.Status == 200 && !r.IsRequestError()
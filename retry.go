@@ -0,0 +1,138 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how requests to the Friendly Captcha API are retried when they fail.
+//
+// Only failures that are safe to retry are retried: connection errors, 5xx responses and 429 responses. A retry is
+// never started if it could not complete before the deadline of the context passed to the Client.
+//
+// A siteverify request that failed may have reached the API anyway, so that the retry is rejected with
+// `response_duplicate`. Then the failure of the earlier attempt is used instead. Every retrieval of risk intelligence
+// counts as a use of the token, so `RetrieveRiskIntelligence` is only retried if the token was certainly not used: on
+// 429 responses, and if no connection could be established.
+//
+// The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time to wait between two attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the backoff grows by after every attempt. Values below 1 are treated as 1.
+	Multiplier float64
+	// Jitter is the fraction (between 0 and 1) of the backoff that is randomized, to avoid many clients retrying in
+	// lockstep. For example 0.2 means that the backoff is randomly reduced by up to 20%.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with sensible defaults: up to 3 attempts, starting with a 100ms backoff
+// that doubles up to 1s, with 20% jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests to the Friendly Captcha API.
+//
+// By default requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		c.RetryPolicy = policy
+		return nil
+	}
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the time to wait after the given (1-based) attempt failed.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// isRetryableStatus returns true for HTTP status codes that signal a (probably) temporary problem on the side of
+// the Friendly Captcha API.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// safeToRetry returns whether a failed request to the path may be sent again.
+func safeToRetry(path string, resp *http.Response, err error) bool {
+	if path != retrievePath {
+		return true
+	}
+	if err != nil {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	return resp.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns the delay requested by the server in the Retry-After header, if any.
+// Only the delay-seconds form is supported.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// waitForRetry sleeps for the given duration. It returns false if the context is done before that, or if its
+// deadline would pass while waiting: then there is no point in retrying.
+func waitForRetry(ctx context.Context, wait time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
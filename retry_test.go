@@ -0,0 +1,161 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	tests := []struct {
+		name             string
+		failures         int
+		fail             http.HandlerFunc
+		expectedAttempts int
+		expectedVerified bool
+	}{
+		{name: "503 is retried", failures: 2, fail: respondWithStatus(503), expectedAttempts: 3, expectedVerified: true},
+		{name: "429 is retried", failures: 1, fail: respondWithStatus(429), expectedAttempts: 2, expectedVerified: true},
+		{name: "dropped connection is retried", failures: 1, fail: dropConnection, expectedAttempts: 2, expectedVerified: true},
+		{name: "gives up after max attempts", failures: 3, fail: respondWithStatus(502), expectedAttempts: 3},
+		{name: "400 is not retried", failures: 1, fail: respondWithStatus(400), expectedAttempts: 1},
+		{name: "401 is not retried", failures: 1, fail: respondWithStatus(401), expectedAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, requests := newTestServer(t, failFirst(tt.failures, tt.fail)...)
+			client := newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(policy))
+
+			result := client.VerifyCaptchaResponse(context.Background(), "response")
+			assert.Equal(t, tt.expectedAttempts, result.Attempts())
+			assert.Equal(t, tt.expectedAttempts, int(requests.Load()))
+			assert.Equal(t, tt.expectedVerified, result.WasAbleToVerify())
+
+			riResult := client.RetrieveRiskIntelligence(context.Background(), "token")
+			assert.Equal(t, 1, riResult.Attempts(), "server has recovered by now")
+		})
+	}
+}
+
+func TestRetryPolicyDisabledByDefault(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, respondWithStatus(503), respondSuccess)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(RetryPolicy{}))
+
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.Equal(t, 1, result.Attempts())
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, 503, result.Status)
+}
+
+func TestRetryPolicyRespectsContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTestServer(t, failFirst(5, respondWithStatus(503))...)
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second}
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := client.VerifyCaptchaResponse(ctx, "response")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "should not wait for a backoff that exceeds the deadline")
+	assert.Equal(t, 1, result.Attempts())
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, 503, result.Status)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 900*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(4))
+	assert.Equal(t, time.Second, policy.backoff(50))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(1)
+		assert.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 100*time.Millisecond)
+	}
+}
+
+func TestRetryPolicyDuplicateAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		first    http.HandlerFunc
+		expected FailureClass
+		status   int
+	}{
+		{name: "502", first: respondWithStatus(http.StatusBadGateway), expected: FailureServerError, status: 502},
+		{name: "dropped connection", first: dropConnection, expected: FailureNetworkError, status: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The failed attempt verified the response, so the retry is rejected as a duplicate.
+			server, requests := newTestServer(t, tt.first, respondDuplicate)
+			client := newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+			result := client.VerifyCaptchaResponse(context.Background(), "response")
+			assert.False(t, result.WasAbleToVerify())
+			assert.Equal(t, tt.expected, result.FailureClass())
+			assert.Equal(t, tt.status, result.Status)
+			assert.True(t, result.ShouldAccept(), "the failure policy decides, as without retries")
+			assert.Equal(t, 2, result.Attempts())
+			assert.Equal(t, int32(2), requests.Load())
+		})
+	}
+}
+
+func TestRetryPolicyRetrieve(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	// The API may have used the token before failing.
+	server, requests := newTestServer(t, respondWithStatus(http.StatusServiceUnavailable), respondSuccess)
+	client := newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(policy))
+	result := client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.False(t, result.WasAbleToRetrieve())
+	assert.Equal(t, 1, result.Attempts())
+	assert.Equal(t, int32(1), requests.Load())
+
+	server, requests = newTestServer(t, respondWithStatus(http.StatusTooManyRequests), respondSuccess)
+	client = newTestClient(t, WithAPIEndpoint(server.URL), WithRetryPolicy(policy))
+	result = client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.True(t, result.WasAbleToRetrieve())
+	assert.Equal(t, 2, result.Attempts())
+	assert.Equal(t, int32(2), requests.Load())
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	client = newTestClient(t, WithAPIEndpoint(closed.URL), WithRetryPolicy(policy))
+	result = client.RetrieveRiskIntelligence(context.Background(), "token")
+	assert.Equal(t, FailureNetworkError, result.FailureClass())
+	assert.Equal(t, 3, result.Attempts(), "the token was not used if no connection could be established")
+}
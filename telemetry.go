@@ -0,0 +1,303 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/friendlycaptcha/friendly-captcha-go"
+
+const (
+	spanNameVerify   = "friendlycaptcha.VerifyCaptchaResponse"
+	spanNameRetrieve = "friendlycaptcha.RetrieveRiskIntelligence"
+
+	operationVerify   = "siteverify"
+	operationRetrieve = "risk_intelligence_retrieve"
+)
+
+// Attribute keys used on spans and metrics.
+const (
+	attrOperation       = attribute.Key("friendlycaptcha.operation")
+	attrOutcome         = attribute.Key("friendlycaptcha.outcome")
+	attrStatusCode      = attribute.Key("http.response.status_code")
+	attrErrorCode       = attribute.Key("friendlycaptcha.error_code")
+	attrEventID         = attribute.Key("friendlycaptcha.event_id")
+	attrStrict          = attribute.Key("friendlycaptcha.strict")
+	attrShouldAccept    = attribute.Key("friendlycaptcha.should_accept")
+	attrFailureClass    = attribute.Key("friendlycaptcha.failure_class")
+	attrAttempts        = attribute.Key("friendlycaptcha.attempts")
+	attrWasAbleToVerify = attribute.Key("friendlycaptcha.was_able_to_verify")
+)
+
+// Outcomes of a call, used as the friendlycaptcha.outcome attribute.
+const (
+	// The captcha response was verified and accepted.
+	outcomeAccepted = "accepted"
+	// The captcha response was verified and rejected, or rejected as a replay.
+	outcomeRejected = "rejected"
+	// The captcha response could not be verified, but was accepted anyway.
+	outcomeFailedOpen = "failed_open"
+	// The captcha response could not be verified, and was rejected.
+	outcomeFailedClosed = "failed_closed"
+
+	// The risk intelligence token was valid.
+	outcomeValid = "valid"
+	// The risk intelligence token was invalid.
+	outcomeInvalid = "invalid"
+	// The risk intelligence could not be retrieved.
+	outcomeFailed = "failed"
+)
+
+// telemetry holds the OpenTelemetry instruments of a Client.
+type telemetry struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	meter      metric.Meter
+	duration   metric.Float64Histogram
+	calls      metric.Int64Counter
+}
+
+// noopTelemetry is used by clients that were not created with NewClient.
+var noopTelemetry = newTelemetry(tracenoop.NewTracerProvider(), metricnoop.NewMeterProvider(), propagation.TraceContext{})
+
+// WithTracerProvider sets the OpenTelemetry TracerProvider used to create a span for every call to
+// `VerifyCaptchaResponse` and `RetrieveRiskIntelligence`.
+//
+// This defaults to the global TracerProvider, see `otel.GetTracerProvider`.
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) error {
+		c.tracerProvider = provider
+		return nil
+	}
+}
+
+// WithMeterProvider sets the OpenTelemetry MeterProvider used to record the latency and outcome of every call to
+// `VerifyCaptchaResponse` and `RetrieveRiskIntelligence`.
+//
+// This defaults to the global MeterProvider, see `otel.GetMeterProvider`.
+func WithMeterProvider(provider metric.MeterProvider) ClientOption {
+	return func(c *Client) error {
+		c.meterProvider = provider
+		return nil
+	}
+}
+
+// WithTextMapPropagator sets the propagator used to inject the trace context into the headers of requests to the
+// Friendly Captcha API.
+//
+// This defaults to W3C Trace Context (`traceparent` and `tracestate` headers).
+func WithTextMapPropagator(propagator propagation.TextMapPropagator) ClientOption {
+	return func(c *Client) error {
+		c.propagator = propagator
+		return nil
+	}
+}
+
+func newTelemetry(
+	tracerProvider trace.TracerProvider,
+	meterProvider metric.MeterProvider,
+	propagator propagation.TextMapPropagator,
+) *telemetry {
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	meter := meterProvider.Meter(instrumentationName, metric.WithInstrumentationVersion(Version))
+
+	// Errors creating instruments are reported to the global OpenTelemetry error handler, we fall back to no-ops.
+	duration, err := meter.Float64Histogram(
+		"friendlycaptcha.client.duration",
+		metric.WithDescription("Duration of calls to the Friendly Captcha API, including retries."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+		duration, _ = metricnoop.Meter{}.Float64Histogram("")
+	}
+	calls, err := meter.Int64Counter(
+		"friendlycaptcha.client.calls",
+		metric.WithDescription("Number of calls to the Friendly Captcha API by outcome."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+		calls, _ = metricnoop.Meter{}.Int64Counter("")
+	}
+
+	return &telemetry{
+		tracer:     tracerProvider.Tracer(instrumentationName, trace.WithInstrumentationVersion(Version)),
+		propagator: propagator,
+		meter:      meter,
+		duration:   duration,
+		calls:      calls,
+	}
+}
+
+// observeLimiter reports the number of calls that are in flight and queued in the limiter as gauges, until the
+// returned function is called.
+func (t *telemetry) observeLimiter(limiter *ConcurrencyLimiter) (release func()) {
+	return limiter.observe(t.meter, func() metric.Registration {
+		inFlight, err := t.meter.Int64ObservableGauge(
+			"friendlycaptcha.client.in_flight",
+			metric.WithDescription("Number of calls to the Friendly Captcha API that are in flight."),
+			metric.WithUnit("{call}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+			return nil
+		}
+		queued, err := t.meter.Int64ObservableGauge(
+			"friendlycaptcha.client.queued",
+			metric.WithDescription("Number of calls to the Friendly Captcha API that wait for a slot in the limiter."),
+			metric.WithUnit("{call}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+			return nil
+		}
+		registration, err := t.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			o.ObserveInt64(inFlight, int64(limiter.InFlight()))
+			o.ObserveInt64(queued, int64(limiter.Queued()))
+			return nil
+		}, inFlight, queued)
+		if err != nil {
+			otel.Handle(err)
+			return nil
+		}
+		return registration
+	})
+}
+
+func (frc *Client) telemetry() *telemetry {
+	if frc.instruments == nil {
+		return noopTelemetry
+	}
+	return frc.instruments
+}
+
+func (t *telemetry) startSpan(ctx context.Context, name string, operation string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrOperation.String(operation)),
+	)
+}
+
+// verifyOutcome returns the value of the outcome attribute for a VerifyResult.
+func verifyOutcome(result VerifyResult) string {
+	accepted := result.ShouldAccept()
+	switch {
+	case result.WasAbleToVerify() && accepted:
+		return outcomeAccepted
+	case result.WasAbleToVerify(), result.IsReplayed():
+		return outcomeRejected
+	case accepted:
+		return outcomeFailedOpen
+	default:
+		return outcomeFailedClosed
+	}
+}
+
+// retrieveOutcome returns the value of the outcome attribute for a RiskIntelligenceRetrieveResult.
+func retrieveOutcome(result RiskIntelligenceRetrieveResult) string {
+	switch {
+	case result.IsValid():
+		return outcomeValid
+	case result.WasAbleToRetrieve():
+		return outcomeInvalid
+	default:
+		return outcomeFailed
+	}
+}
+
+func (t *telemetry) endVerify(ctx context.Context, span trace.Span, result VerifyResult, elapsed time.Duration) {
+	outcome := verifyOutcome(result)
+
+	span.SetAttributes(
+		attrOutcome.String(outcome),
+		attrStatusCode.Int(result.Status),
+		attrStrict.Bool(result.Strict()),
+		attrShouldAccept.Bool(result.ShouldAccept()),
+		attrWasAbleToVerify.Bool(result.WasAbleToVerify()),
+		attrAttempts.Int(result.Attempts()),
+	)
+	if code := result.ErrorCode(); code != "" {
+		span.SetAttributes(attrErrorCode.String(string(code)))
+	}
+	if data := result.response.Data; data != nil && data.EventID != "" {
+		span.SetAttributes(attrEventID.String(data.EventID))
+	}
+	t.endSpan(span, result.FailureClass(), result.RequestError())
+
+	t.record(ctx, operationVerify, outcome, result.FailureClass(), elapsed)
+}
+
+func (t *telemetry) endRetrieve(
+	ctx context.Context,
+	span trace.Span,
+	result RiskIntelligenceRetrieveResult,
+	elapsed time.Duration,
+) {
+	outcome := retrieveOutcome(result)
+
+	span.SetAttributes(
+		attrOutcome.String(outcome),
+		attrStatusCode.Int(result.Status),
+		attrAttempts.Int(result.Attempts()),
+	)
+	if code := result.ErrorCode(); code != "" {
+		span.SetAttributes(attrErrorCode.String(string(code)))
+	}
+	if data := result.response.Data; data != nil && data.EventID != "" {
+		span.SetAttributes(attrEventID.String(data.EventID))
+	}
+	t.endSpan(span, result.FailureClass(), result.RequestError())
+
+	t.record(ctx, operationRetrieve, outcome, result.FailureClass(), elapsed)
+}
+
+func (t *telemetry) endSpan(span trace.Span, failure FailureClass, err error) {
+	if err != nil {
+		span.SetAttributes(attrFailureClass.String(string(failure)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, string(failure))
+	}
+	span.End()
+}
+
+func (t *telemetry) record(
+	ctx context.Context,
+	operation string,
+	outcome string,
+	failure FailureClass,
+	elapsed time.Duration,
+) {
+	attrs := []attribute.KeyValue{
+		attrOperation.String(operation),
+		attrOutcome.String(outcome),
+	}
+	if failure != FailureNone {
+		attrs = append(attrs, attrFailureClass.String(string(failure)))
+	}
+	t.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	t.calls.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// injectTraceContext adds the trace context headers to an outgoing request.
+func (t *telemetry) injectTraceContext(ctx context.Context, header propagation.HeaderCarrier) {
+	t.propagator.Inject(ctx, header)
+}
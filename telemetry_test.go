@@ -0,0 +1,110 @@
+package friendlycaptcha_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	friendlycaptcha "github.com/friendlycaptcha/friendly-captcha-go"
+	"github.com/friendlycaptcha/friendly-captcha-go/friendlycaptchatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+
+	server := friendlycaptchatest.NewServer()
+	defer server.Close()
+	server.OnVerify("valid", friendlycaptchatest.VerifySuccess())
+	server.OnVerify("outage", friendlycaptchatest.Status(http.StatusServiceUnavailable, "oops"))
+	server.OnRetrieve("token", friendlycaptchatest.RetrieveSuccess(friendlycaptcha.RiskIntelligenceData{}))
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	client := server.Client(
+		friendlycaptcha.WithTracerProvider(tracerProvider),
+		friendlycaptcha.WithMeterProvider(meterProvider),
+	)
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	client.VerifyCaptchaResponse(ctx, "valid")
+	client.VerifyCaptchaResponse(ctx, "outage")
+	client.RetrieveRiskIntelligence(ctx, "token")
+	parent.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 4)
+
+	valid := spans[0]
+	assert.Equal(t, "friendlycaptcha.VerifyCaptchaResponse", valid.Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), valid.SpanContext().TraceID())
+	attrs := spanAttributes(valid)
+	assert.Equal(t, int64(200), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "ev_friendlycaptchatest", attrs["friendlycaptcha.event_id"].AsString())
+	assert.Equal(t, "accepted", attrs["friendlycaptcha.outcome"].AsString())
+	assert.True(t, attrs["friendlycaptcha.should_accept"].AsBool())
+	assert.False(t, attrs["friendlycaptcha.strict"].AsBool())
+	assert.Equal(t, codes.Unset, valid.Status().Code)
+
+	outage := spans[1]
+	attrs = spanAttributes(outage)
+	assert.Equal(t, int64(503), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "failed_open", attrs["friendlycaptcha.outcome"].AsString())
+	assert.Equal(t, "server_error", attrs["friendlycaptcha.failure_class"].AsString())
+	assert.Equal(t, codes.Error, outage.Status().Code)
+
+	retrieve := spans[2]
+	assert.Equal(t, "friendlycaptcha.RetrieveRiskIntelligence", retrieve.Name())
+	assert.Equal(t, "valid", spanAttributes(retrieve)["friendlycaptcha.outcome"].AsString())
+
+	// The trace context is propagated to the API.
+	requests := server.Requests()
+	require.Len(t, requests, 3)
+	for i, request := range requests {
+		traceparent := request.Header.Get("Traceparent")
+		assert.Contains(t, traceparent, parent.SpanContext().TraceID().String())
+		assert.Contains(t, traceparent, spans[i].SpanContext().SpanID().String())
+	}
+
+	var metrics metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &metrics))
+	require.Len(t, metrics.ScopeMetrics, 1)
+
+	counts := make(map[string]int64)
+	var histogramCount uint64
+	for _, m := range metrics.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			assert.Equal(t, "friendlycaptcha.client.calls", m.Name)
+			for _, dp := range data.DataPoints {
+				outcome, _ := dp.Attributes.Value("friendlycaptcha.outcome")
+				counts[outcome.AsString()] += dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			assert.Equal(t, "friendlycaptcha.client.duration", m.Name)
+			for _, dp := range data.DataPoints {
+				histogramCount += dp.Count
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"accepted": 1, "failed_open": 1, "valid": 1}, counts)
+	assert.Equal(t, uint64(3), histogramCount)
+}
@@ -0,0 +1,145 @@
+package friendlycaptcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts a fake Friendly Captcha API that handles the nth request with the nth handler, and later
+// requests with the last one. Without handlers every request is answered with respondSuccess. It returns the server
+// and a counter of received requests.
+func newTestServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	if len(handlers) == 0 {
+		handlers = []http.HandlerFunc{respondSuccess}
+	}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices that the client went away once the body was read.
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		n := int(requests.Add(1))
+		handlers[min(n, len(handlers))-1](w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newTestClient returns a Client with a test API key and the given options, e.g. WithAPIEndpoint(server.URL).
+func newTestClient(t *testing.T, opts ...ClientOption) *Client {
+	t.Helper()
+
+	client, err := NewClient(append([]ClientOption{WithAPIKey("test-key")}, opts...)...)
+	require.NoError(t, err)
+	return client
+}
+
+// failFirst returns handlers for newTestServer that fail the first n requests with fail, and respond successfully
+// afterwards.
+func failFirst(n int, fail http.HandlerFunc) []http.HandlerFunc {
+	handlers := make([]http.HandlerFunc, 0, n+1)
+	for range n {
+		handlers = append(handlers, fail)
+	}
+	return append(handlers, respondSuccess)
+}
+
+// respondIfValid verifies the captcha response "valid", solved on https://example.com, and rejects any other response.
+func respondIfValid(w http.ResponseWriter, r *http.Request) {
+	var req VerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"success":false,"error":{"error_code":"bad_request"}}`))
+		return
+	}
+	if req.Response != "valid" {
+		_, _ = w.Write([]byte(`{"success":false,"error":{"error_code":"response_invalid"}}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"success":true,"data":{"event_id":"ev_test","challenge":{"origin":"https://example.com"}}}`))
+}
+
+// recordedRequest is a request received by a handler of recordRequests.
+type recordedRequest struct {
+	sitekey string
+	header  http.Header
+}
+
+// recordRequests returns a handler that records the requests and responds with respondSuccess, and a function that
+// returns the recorded requests.
+func recordRequests() (http.HandlerFunc, func() []recordedRequest) {
+	var mu sync.Mutex
+	var requests []recordedRequest
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Sitekey string `json:"sitekey"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, recordedRequest{sitekey: body.Sitekey, header: r.Header.Clone()})
+		mu.Unlock()
+		respondSuccess(w, r)
+	}
+	return handler, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+// respondAfter returns a handler that responds with the given handler after the delay, unless the request is canceled
+// before. The channel is closed if the request was canceled.
+func respondAfter(delay time.Duration, handler http.HandlerFunc) (http.HandlerFunc, <-chan struct{}) {
+	canceled := make(chan struct{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			handler(w, r)
+		case <-r.Context().Done():
+			close(canceled)
+		}
+	}, canceled
+}
+
+// respondWhenReleased returns a handler that responds with the given handler once release is closed.
+func respondWhenReleased(release <-chan struct{}, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			handler(w, r)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func respondSuccess(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(`{"success":true,"data":{"event_id":"ev_test"}}`))
+}
+
+func respondDuplicate(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(`{"success":false,"error":{"error_code":"response_duplicate"}}`))
+}
+
+func respondWithStatus(statusCode int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(`{"success":false,"error":{"error_code":"bad_request"}}`))
+	}
+}
+
+func dropConnection(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,168 @@
+package friendlycaptcha
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the timeout of a single request to the Friendly Captcha API, see WithTimeout.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxConnsPerHost is how many connections the default transport opens to an API endpoint at most, see
+	// WithTransport.
+	DefaultMaxConnsPerHost = 64
+)
+
+// transportConfig holds the options that configure the http.Client that NewClient creates, unless WithHTTPClient was
+// used.
+type transportConfig struct {
+	timeout   time.Duration
+	transport http.RoundTripper
+	rootCAs   *x509.CertPool
+	proxy     *url.URL
+	certs     []tls.Certificate
+}
+
+// customizesTransport returns whether options that configure the default transport were used.
+func (cfg transportConfig) customizesTransport() bool {
+	return cfg.rootCAs != nil || cfg.proxy != nil || len(cfg.certs) > 0
+}
+
+// WithTimeout sets the timeout of a single request to the Friendly Captcha API, including reading the response. Every
+// retry gets its own timeout, use WithCallTimeout to bound a whole call. A timeout of 0 disables it, so that requests
+// are only bounded by the context.
+//
+// If WithHTTPClient was used before, the timeout is set on a copy of that client.
+//
+// This defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout must not be negative")
+		}
+		if c.HTTPClient != nil {
+			httpClient := *c.HTTPClient
+			httpClient.Timeout = timeout
+			c.HTTPClient = &httpClient
+			return nil
+		}
+		c.transportConfig.timeout = timeout
+		return nil
+	}
+}
+
+// WithTransport sets the transport used for requests to the Friendly Captcha API, e.g. to add instrumentation. It
+// can't be combined with WithHTTPClient, WithRootCAs, WithProxy or WithClientCertificate.
+//
+// This defaults to an `http.Transport` tuned for the Friendly Captcha API: connections are kept alive and reused, at
+// most DefaultMaxConnsPerHost connections are opened per endpoint, and the proxy is taken from the environment (see
+// `http.ProxyFromEnvironment`).
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		if transport == nil {
+			return fmt.Errorf("transport must not be nil")
+		}
+		if c.HTTPClient != nil {
+			return fmt.Errorf("WithTransport can't be combined with WithHTTPClient")
+		}
+		c.transportConfig.transport = transport
+		return nil
+	}
+}
+
+// WithRootCAs sets the certificate authorities that are trusted for connections to the Friendly Captcha API, e.g. when
+// a corporate proxy intercepts TLS. It can't be combined with WithHTTPClient or WithTransport.
+//
+// This defaults to the certificate authorities of the host.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(c *Client) error {
+		if pool == nil {
+			return fmt.Errorf("root CAs must not be nil")
+		}
+		if c.HTTPClient != nil {
+			return fmt.Errorf("WithRootCAs can't be combined with WithHTTPClient")
+		}
+		c.transportConfig.rootCAs = pool
+		return nil
+	}
+}
+
+// WithProxy sends requests to the Friendly Captcha API through the given HTTP proxy, e.g. "http://proxy.internal:3128".
+// It can't be combined with WithHTTPClient or WithTransport.
+//
+// This defaults to the proxy configured in the environment, see `http.ProxyFromEnvironment`.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q: must have a scheme and host", proxyURL)
+		}
+		if c.HTTPClient != nil {
+			return fmt.Errorf("WithProxy can't be combined with WithHTTPClient")
+		}
+		c.transportConfig.proxy = u
+		return nil
+	}
+}
+
+// WithClientCertificate presents the certificate on connections to the Friendly Captcha API, for egress gateways that
+// require mutual TLS. It can't be combined with WithHTTPClient or WithTransport.
+//
+// By default no client certificate is presented.
+func WithClientCertificate(cert tls.Certificate) ClientOption {
+	return func(c *Client) error {
+		if c.HTTPClient != nil {
+			return fmt.Errorf("WithClientCertificate can't be combined with WithHTTPClient")
+		}
+		c.transportConfig.certs = append(c.transportConfig.certs, cert)
+		return nil
+	}
+}
+
+// newHTTPClient returns the http.Client for the transport options.
+func newHTTPClient(cfg transportConfig) (*http.Client, error) {
+	transport := cfg.transport
+	if transport == nil {
+		transport = newTransport(cfg)
+	} else if cfg.customizesTransport() {
+		return nil, fmt.Errorf("WithRootCAs, WithProxy and WithClientCertificate can't be combined with WithTransport")
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.timeout}, nil
+}
+
+// newTransport returns a transport tuned for the Friendly Captcha API: all requests go to one or two hosts, so most
+// idle connections are kept for them.
+func newTransport(cfg transportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	proxy := http.ProxyFromEnvironment
+	if cfg.proxy != nil {
+		proxy = http.ProxyURL(cfg.proxy)
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          DefaultMaxConnsPerHost,
+		MaxIdleConnsPerHost:   DefaultMaxConnsPerHost,
+		MaxConnsPerHost:       DefaultMaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      cfg.rootCAs,
+			Certificates: cfg.certs,
+		},
+	}
+}
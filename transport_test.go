@@ -0,0 +1,158 @@
+package friendlycaptcha
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientDefaultHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(WithAPIKey("test-key"))
+	require.NoError(t, err)
+	assert.NotSame(t, http.DefaultClient, client.HTTPClient)
+	assert.Equal(t, DefaultTimeout, client.HTTPClient.Timeout)
+
+	transport, ok := client.HTTPClient.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, DefaultMaxConnsPerHost, transport.MaxConnsPerHost)
+	assert.Equal(t, DefaultMaxConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.NotNil(t, transport.Proxy)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(WithAPIKey("test-key"), WithTimeout(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, client.HTTPClient.Timeout)
+
+	client, err = NewClient(WithAPIKey("test-key"), WithTimeout(0))
+	require.NoError(t, err)
+	assert.Zero(t, client.HTTPClient.Timeout)
+
+	// The client passed to WithHTTPClient is not modified.
+	httpClient := &http.Client{}
+	client, err = NewClient(WithAPIKey("test-key"), WithHTTPClient(httpClient), WithTimeout(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, client.HTTPClient.Timeout)
+	assert.Zero(t, httpClient.Timeout)
+
+	_, err = NewClient(WithAPIKey("test-key"), WithTimeout(-time.Second))
+	assert.Error(t, err)
+}
+
+func TestWithTransport(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTestServer(t)
+	transport := &countingTransport{}
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL), WithTransport(transport))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, client.HTTPClient.Timeout)
+
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").WasAbleToVerify())
+	assert.Equal(t, int32(1), transport.requests.Load())
+}
+
+func TestWithProxy(t *testing.T) {
+	t.Parallel()
+
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		respondSuccess(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewClient(WithAPIKey("test-key"), WithAPIEndpoint("http://api.invalid"), WithProxy(proxy.URL))
+	require.NoError(t, err)
+
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").WasAbleToVerify())
+	assert.Equal(t, "http://api.invalid"+siteverifyPath, proxied.Load())
+}
+
+func TestWithRootCAsAndClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	var clientCerts atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts.Store(int32(len(r.TLS.PeerCertificates)))
+		respondSuccess(w, r)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	cert := server.TLS.Certificates[0]
+
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithAPIEndpoint(server.URL),
+		WithRootCAs(roots),
+		WithClientCertificate(cert),
+	)
+	require.NoError(t, err)
+	assert.True(t, client.VerifyCaptchaResponse(context.Background(), "response").WasAbleToVerify())
+	assert.Equal(t, int32(1), clientCerts.Load())
+
+	// The server is not trusted without the root CA.
+	client, err = NewClient(WithAPIKey("test-key"), WithAPIEndpoint(server.URL), WithClientCertificate(cert))
+	require.NoError(t, err)
+	result := client.VerifyCaptchaResponse(context.Background(), "response")
+	assert.False(t, result.WasAbleToVerify())
+	assert.Equal(t, FailureNetworkError, result.FailureClass())
+}
+
+func TestTransportOptionConflicts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+	}{
+		{name: "transport and root CAs", opts: []ClientOption{WithTransport(&countingTransport{}), WithRootCAs(x509.NewCertPool())}},
+		{name: "proxy and transport", opts: []ClientOption{WithProxy("http://proxy.internal:3128"), WithTransport(&countingTransport{})}},
+		{name: "HTTP client and transport", opts: []ClientOption{WithHTTPClient(&http.Client{}), WithTransport(&countingTransport{})}},
+		{name: "HTTP client and proxy", opts: []ClientOption{WithHTTPClient(&http.Client{}), WithProxy("http://proxy.internal:3128")}},
+		{name: "nil transport", opts: []ClientOption{WithTransport(nil)}},
+		{name: "nil root CAs", opts: []ClientOption{WithRootCAs(nil)}},
+		{name: "proxy without scheme", opts: []ClientOption{WithProxy("proxy.internal:3128")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewClient(append([]ClientOption{WithAPIKey("test-key")}, tt.opts...)...)
+			assert.Error(t, err)
+		})
+	}
+
+	// WithHTTPClient overrides earlier transport options.
+	httpClient := &http.Client{}
+	client, err := NewClient(WithAPIKey("test-key"), WithProxy("http://proxy.internal:3128"), WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	assert.Same(t, httpClient, client.HTTPClient)
+}
@@ -0,0 +1,24 @@
+package friendlycaptcha
+
+import "context"
+
+// Verifier verifies captcha responses, it is implemented by *Client.
+//
+// Depend on this interface instead of *Client in your application code, so that it can be replaced in tests. The
+// friendlycaptchatest package contains ready-made implementations.
+type Verifier interface {
+	VerifyCaptchaResponse(ctx context.Context, captchaResponse string, opts ...CallOption) VerifyResult
+}
+
+// RiskIntelligenceRetriever retrieves risk intelligence data, it is implemented by *Client.
+//
+// Depend on this interface instead of *Client in your application code, so that it can be replaced in tests. The
+// friendlycaptchatest package contains ready-made implementations.
+type RiskIntelligenceRetriever interface {
+	RetrieveRiskIntelligence(ctx context.Context, token string, opts ...CallOption) RiskIntelligenceRetrieveResult
+}
+
+var (
+	_ Verifier                  = (*Client)(nil)
+	_ RiskIntelligenceRetriever = (*Client)(nil)
+)